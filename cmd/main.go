@@ -2,12 +2,151 @@ package main
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jessevdk/go-flags"
 
 	"github.com/odonate/postgres-exporter/exporter"
+	"github.com/odonate/postgres-exporter/exporter/collectors"
+	"github.com/odonate/postgres-exporter/exporter/config"
 	"github.com/odonate/postgres-exporter/exporter/db"
+	"github.com/odonate/postgres-exporter/exporter/logging"
 )
 
+var log = logging.NewLogger()
+
+var opts struct {
+	DB          db.Opts         `group:"Postgres"`
+	Collectors  collectors.Opts `group:"Collectors"`
+	ConfigFile  string          `long:"config_file" env:"CONFIG_FILE" description:"YAML or TOML file describing the targets to scrape, instead of the Postgres flags"`
+	QueriesFile string          `long:"queries_file" env:"QUERIES_FILE" description:"YAML file of user-defined queries to export as metrics"`
+	// HTTP server.
+	ListenAddress   string        `long:"listen_address" env:"LISTEN_ADDRESS" default:":13434" description:"Address on which to expose metrics"`
+	MetricsPath     string        `long:"metrics_path" env:"METRICS_PATH" default:"/metrics" description:"Path under which to expose metrics"`
+	ShutdownTimeout time.Duration `long:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" default:"10s" description:"Time to wait for in-flight requests to finish when shutting down"`
+	ProbeHosts      []string      `long:"probe_host" env:"PROBE_HOSTS" env-delim:"," description:"Host that /probe may connect to given a DSN target, repeatable (* is any host). DSN targets are refused unless given"`
+	// Scrapes.
+	ScrapeTimeout       time.Duration `long:"scrape_timeout" env:"SCRAPE_TIMEOUT" default:"0s" description:"Timeout of scrapes when Prometheus does not set one (0 is unbounded)"`
+	ScrapeTimeoutOffset time.Duration `long:"scrape_timeout_offset" env:"SCRAPE_TIMEOUT_OFFSET" default:"500ms" description:"Subtracted from the scrape timeout set by Prometheus, leaving time to send the metrics"`
+	// Background scrapes.
+	BackgroundScrape   bool                     `long:"background_scrape" env:"BACKGROUND_SCRAPE" description:"Scrape collectors in the background on their own intervals, serving their last good snapshot"`
+	BackgroundInterval time.Duration            `long:"background_interval" env:"BACKGROUND_INTERVAL" default:"30s" description:"Interval between background scrapes of a collector"`
+	CollectorIntervals map[string]time.Duration `long:"collector_interval" env:"COLLECTOR_INTERVALS" env-delim:"," description:"Interval between background scrapes of the named collector, e.g. pg_stat_statements:5m"`
+	MaxStaleness       time.Duration            `long:"max_staleness" env:"MAX_STALENESS" default:"0s" description:"Age after which background snapshots are dropped rather than served (0 is never)"`
+}
+
 func main() {
-	exporter := exporter.MustNew(context.Background(), exporter.Opts{DBOpts: []db.Opts{{}}})
-	exporter.Register()
+	if err := parseFlags(); err != nil {
+		var flagsErr *flags.Error
+		if errors.As(err, &flagsErr) && flagsErr.Type == flags.ErrHelp {
+			os.Stdout.WriteString(err.Error() + "\n")
+			os.Exit(0)
+		}
+		log.Fatalf("parsing flags: %s", err)
+	}
+	exporterOpts, err := loadOpts()
+	if err != nil {
+		log.Fatalf("loading config: %s", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	pgExporter := exporter.MustNew(ctx, exporterOpts)
+	defer pgExporter.Close()
+	if opts.QueriesFile != "" {
+		factory, err := collectors.QueriesFactory(opts.QueriesFile)
+		if err != nil {
+			log.Fatalf("loading queries: %s", err)
+		}
+		pgExporter.WithCustomCollectorFactories(factory)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(opts.MetricsPath, pgExporter.Handler())
+	mux.Handle("/probe", pgExporter.ProbeHandler())
+	// Liveness: the exporter serves regardless of its databases being down.
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	// Readiness: every database is up.
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := pgExporter.HealthCheck(r.Context()); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+	if opts.ConfigFile != "" {
+		reloader := exporter.NewReloader(pgExporter, loadOpts)
+		mux.Handle("/-/reload", reloader.Handler())
+		go reloader.WatchSignals(ctx)
+		go func() {
+			if err := reloader.WatchFile(ctx, opts.ConfigFile); err != nil {
+				log.Errorf("watching config: %s", err)
+			}
+		}()
+	}
+	server := &http.Server{
+		Addr:    opts.ListenAddress,
+		Handler: mux,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Infof("serving metrics on %s%s", opts.ListenAddress, opts.MetricsPath)
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Errorf("serving: %s", err)
+		}
+	case <-ctx.Done():
+		log.Infof("shutting down")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.ShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Errorf("shutting down server: %s", err)
+		}
+	}
+}
+
+// loadOpts returns the exporter opts given by the flags, or the config file if any.
+func loadOpts() (exporter.Opts, error) {
+	exporterOpts := exporter.Opts{DBOpts: []db.Opts{opts.DB}, CollectorOpts: &opts.Collectors}
+	if opts.ConfigFile != "" {
+		var err error
+		if exporterOpts, err = config.Load(opts.ConfigFile); err != nil {
+			return exporter.Opts{}, err
+		}
+	}
+	exporterOpts.ProbeHosts = opts.ProbeHosts
+	exporterOpts.ScrapeTimeout = opts.ScrapeTimeout
+	exporterOpts.ScrapeTimeoutOffset = opts.ScrapeTimeoutOffset
+	exporterOpts.Background = exporter.BackgroundOpts{
+		Enabled:      opts.BackgroundScrape,
+		Interval:     opts.BackgroundInterval,
+		Intervals:    opts.CollectorIntervals,
+		MaxStaleness: opts.MaxStaleness,
+	}
+	return exporterOpts, nil
+}
+
+// parseFlags parses the flags, for which the required Postgres flags are not required
+// when the targets are read from a config file.
+func parseFlags() error {
+	parser := flags.NewParser(&opts, flags.HelpFlag|flags.PassDoubleDash)
+	_, err := parser.Parse()
+	var flagsErr *flags.Error
+	if errors.As(err, &flagsErr) && flagsErr.Type == flags.ErrRequired && opts.ConfigFile != "" {
+		return nil
+	}
+	return err
 }
@@ -0,0 +1,187 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultBackgroundInterval is the interval between background scrapes of a collector
+// when none is configured.
+const defaultBackgroundInterval = 30 * time.Second
+
+// defaultCollectorIntervals are the intervals of expensive collectors when none is configured
+// for them, taking precedence over the interval of every collector.
+var defaultCollectorIntervals = map[string]time.Duration{
+	"pg_bloat": time.Hour,
+}
+
+// checkBackground checks that the collectors too expensive to run on every scrape, those with a
+// default interval, are only run by targets scraped in the background.
+func checkBackground(targets []Target, opts BackgroundOpts) error {
+	if opts.Enabled {
+		return nil
+	}
+	for _, target := range targets {
+		for _, name := range target.Collectors {
+			if _, ok := defaultCollectorIntervals[name]; ok {
+				return fmt.Errorf("%s: collector %s requires background scrapes", target.DBOpts.TargetName(), name)
+			}
+		}
+	}
+	return nil
+}
+
+// BackgroundOpts configure collectors to scrape their targets in the background, each on its
+// own interval, with scrapes of the exporter serving the last good snapshot of every collector.
+type BackgroundOpts struct {
+	Enabled bool
+	// Interval between background scrapes of a collector, defaulting to 30s.
+	Interval time.Duration
+	// Intervals overrides the interval by collector name, e.g. pg_stat_statements.
+	// pg_bloat defaults to 1h rather than the interval, and is only run in the background.
+	Intervals map[string]time.Duration
+	// MaxStaleness is the age after which a snapshot is dropped rather than served,
+	// zero serves snapshots of any age.
+	MaxStaleness time.Duration
+}
+
+// interval returns the interval between background scrapes of the collector.
+func (o BackgroundOpts) interval(collector string) time.Duration {
+	if interval, ok := o.Intervals[collector]; ok && interval > 0 {
+		return interval
+	}
+	if interval, ok := defaultCollectorIntervals[collector]; ok {
+		return interval
+	}
+	if o.Interval > 0 {
+		return o.Interval
+	}
+	return defaultBackgroundInterval
+}
+
+// background scrapes the collectors of a target until stopped.
+type background struct {
+	scrapers []*backgroundScraper
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+// backgroundScraper holds the last snapshot of a collector scraped in the background.
+type backgroundScraper struct {
+	collector namedCollector
+	interval  time.Duration
+
+	mutex sync.RWMutex
+	// Metrics and time of the last good scrape.
+	metrics   []prometheus.Metric
+	scrapedAt time.Time
+	// Time, duration and error of the last scrape.
+	lastScrape time.Time
+	duration   time.Duration
+	err        error
+}
+
+// startBackground starts scraping every collector of the target in the background,
+// returning nil if background scrapes are disabled.
+func (e *Exporter) startBackground(target *target, opts BackgroundOpts) *background {
+	if !opts.Enabled {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &background{cancel: cancel}
+	for _, collector := range target.collectors {
+		scraper := &backgroundScraper{collector: collector, interval: opts.interval(collector.name)}
+		b.scrapers = append(b.scrapers, scraper)
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			e.runBackground(ctx, target, scraper)
+		}()
+	}
+	return b
+}
+
+// stop stops the background scrapes and waits for in-flight ones to finish.
+func (b *background) stop() {
+	if b == nil {
+		return
+	}
+	b.cancel()
+	b.wg.Wait()
+}
+
+// runBackground scrapes the collector on its interval until the context is done.
+func (e *Exporter) runBackground(ctx context.Context, target *target, scraper *backgroundScraper) {
+	ticker := time.NewTicker(scraper.interval)
+	defer ticker.Stop()
+	for {
+		e.scrapeBackground(ctx, target, scraper)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scrapeBackground runs the collector, bounded by its interval, and snapshots its metrics.
+func (e *Exporter) scrapeBackground(ctx context.Context, target *target, scraper *backgroundScraper) {
+	ctx, cancel := context.WithTimeout(ctx, scraper.interval)
+	defer cancel()
+	start := time.Now()
+	var metrics []prometheus.Metric
+	err := e.runCollector(ctx, scraper.collector, target, func(metric prometheus.Metric) {
+		metrics = append(metrics, metric)
+	})
+	duration := time.Since(start)
+
+	scraper.mutex.Lock()
+	defer scraper.mutex.Unlock()
+	scraper.lastScrape = start
+	scraper.duration = duration
+	scraper.err = err
+	if err == nil {
+		scraper.metrics = metrics
+		scraper.scrapedAt = start
+	}
+}
+
+// serveBackground serves the snapshots of the target, reporting it as up if the last
+// background scrape of every collector succeeded. Collectors yet to be scraped are skipped.
+func (e *Exporter) serveBackground(target *target, b *background, maxStaleness time.Duration, ch chan<- prometheus.Metric) {
+	now := time.Now()
+	up := target.dbClient.Connected()
+	for _, scraper := range b.scrapers {
+		if !e.serveSnapshot(target, scraper, maxStaleness, now, ch) {
+			up = false
+		}
+	}
+	e.reportTarget(target, up, ch)
+}
+
+// serveSnapshot serves the snapshot of the collector unless staler than maxStaleness,
+// reporting whether its last background scrape succeeded.
+func (e *Exporter) serveSnapshot(target *target, scraper *backgroundScraper, maxStaleness time.Duration, now time.Time, ch chan<- prometheus.Metric) bool {
+	scraper.mutex.RLock()
+	defer scraper.mutex.RUnlock()
+	if scraper.lastScrape.IsZero() {
+		return true
+	}
+	name, targetName := scraper.collector.name, target.name()
+	if !scraper.scrapedAt.IsZero() {
+		age := now.Sub(scraper.scrapedAt)
+		if maxStaleness <= 0 || age <= maxStaleness {
+			for _, metric := range scraper.metrics {
+				ch <- metric
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(e.cacheAge, prometheus.GaugeValue, age.Seconds(), name, targetName)
+	}
+	e.reportCollector(name, targetName, scraper.err, scraper.duration, ch)
+	ch <- prometheus.MustNewConstMetric(e.lastScrapeTimestamp, prometheus.GaugeValue, float64(scraper.lastScrape.UnixNano())/1e9, name, targetName)
+	return scraper.err == nil
+}
@@ -0,0 +1,145 @@
+package exporter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestServeSnapshot(t *testing.T) {
+	now := time.Now()
+	snapshotDesc := prometheus.NewDesc("snapshot", "Metric of a snapshot.", nil, nil)
+	snapshot := []prometheus.Metric{
+		prometheus.MustNewConstMetric(snapshotDesc, prometheus.GaugeValue, 1),
+		prometheus.MustNewConstMetric(snapshotDesc, prometheus.GaugeValue, 2),
+	}
+	errScrape := errors.New("scrape failed")
+
+	tests := []struct {
+		name         string
+		scraper      *backgroundScraper
+		maxStaleness time.Duration
+		ok           bool
+		// Expected metrics, with negative ages and timestamps meaning absent.
+		served     int
+		cacheAge   float64
+		lastScrape float64
+		success    float64
+	}{
+		{
+			name:       "not scraped yet",
+			scraper:    &backgroundScraper{},
+			ok:         true,
+			cacheAge:   -1,
+			lastScrape: -1,
+		},
+		{
+			name:       "fresh",
+			scraper:    &backgroundScraper{metrics: snapshot, scrapedAt: now.Add(-10 * time.Second), lastScrape: now.Add(-10 * time.Second)},
+			ok:         true,
+			served:     2,
+			cacheAge:   10,
+			lastScrape: float64(now.Add(-10*time.Second).UnixNano()) / 1e9,
+			success:    1,
+		},
+		{
+			name:         "within max staleness",
+			scraper:      &backgroundScraper{metrics: snapshot, scrapedAt: now.Add(-time.Minute), lastScrape: now.Add(-time.Minute)},
+			maxStaleness: time.Minute,
+			ok:           true,
+			served:       2,
+			cacheAge:     60,
+			lastScrape:   float64(now.Add(-time.Minute).UnixNano()) / 1e9,
+			success:      1,
+		},
+		{
+			name:         "past max staleness",
+			scraper:      &backgroundScraper{metrics: snapshot, scrapedAt: now.Add(-2 * time.Minute), lastScrape: now.Add(-time.Second), err: errScrape},
+			maxStaleness: time.Minute,
+			cacheAge:     120,
+			lastScrape:   float64(now.Add(-time.Second).UnixNano()) / 1e9,
+		},
+		{
+			name:       "failed after a good scrape",
+			scraper:    &backgroundScraper{metrics: snapshot, scrapedAt: now.Add(-2 * time.Minute), lastScrape: now.Add(-time.Second), err: errScrape},
+			served:     2,
+			cacheAge:   120,
+			lastScrape: float64(now.Add(-time.Second).UnixNano()) / 1e9,
+		},
+		{
+			name:       "never scraped successfully",
+			scraper:    &backgroundScraper{lastScrape: now.Add(-time.Second), err: errScrape},
+			cacheAge:   -1,
+			lastScrape: float64(now.Add(-time.Second).UnixNano()) / 1e9,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := newExporter(nil)
+			target := &target{dbClient: newTestProbeClient(t, "target").dbClient}
+			scraper := test.scraper
+			scraper.collector = namedCollector{name: "pg_test"}
+
+			ch := make(chan prometheus.Metric, 100)
+			ok := e.serveSnapshot(target, scraper, test.maxStaleness, now, ch)
+			close(ch)
+			if ok != test.ok {
+				t.Errorf("serveSnapshot = %t, want %t", ok, test.ok)
+			}
+
+			served, cacheAge, lastScrape, success := 0, -1.0, -1.0, -1.0
+			for metric := range ch {
+				var out dto.Metric
+				if err := metric.Write(&out); err != nil {
+					t.Fatal(err)
+				}
+				switch metric.Desc() {
+				case snapshotDesc:
+					served++
+				case e.cacheAge:
+					cacheAge = out.GetGauge().GetValue()
+				case e.lastScrapeTimestamp:
+					lastScrape = out.GetGauge().GetValue()
+				case e.scrapeCollectorSuccess:
+					success = out.GetGauge().GetValue()
+				}
+			}
+			if served != test.served {
+				t.Errorf("served %d metrics of the snapshot, want %d", served, test.served)
+			}
+			if cacheAge != test.cacheAge {
+				t.Errorf("cache age = %v, want %v", cacheAge, test.cacheAge)
+			}
+			if lastScrape != test.lastScrape {
+				t.Errorf("last scrape timestamp = %v, want %v", lastScrape, test.lastScrape)
+			}
+			if test.lastScrape >= 0 && success != test.success {
+				t.Errorf("collector success = %v, want %v", success, test.success)
+			}
+		})
+	}
+}
+
+func TestBackgroundInterval(t *testing.T) {
+	opts := BackgroundOpts{Interval: time.Minute, Intervals: map[string]time.Duration{"pg_stat_statements": 5 * time.Minute, "pg_bloat": 6 * time.Hour}}
+	tests := []struct {
+		opts      BackgroundOpts
+		collector string
+		interval  time.Duration
+	}{
+		{opts: BackgroundOpts{}, collector: "pg_locks", interval: defaultBackgroundInterval},
+		{opts: BackgroundOpts{}, collector: "pg_bloat", interval: time.Hour},
+		{opts: opts, collector: "pg_locks", interval: time.Minute},
+		{opts: opts, collector: "pg_stat_statements", interval: 5 * time.Minute},
+		{opts: opts, collector: "pg_bloat", interval: 6 * time.Hour},
+		{opts: BackgroundOpts{Interval: time.Minute}, collector: "pg_bloat", interval: time.Hour},
+	}
+	for _, test := range tests {
+		if interval := test.opts.interval(test.collector); interval != test.interval {
+			t.Errorf("interval(%s) of %+v = %s, want %s", test.collector, test.opts, interval, test.interval)
+		}
+	}
+}
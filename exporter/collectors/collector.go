@@ -1,6 +1,8 @@
 package collectors
 
 import (
+	"context"
+
 	"github.com/odonate/postgres-exporter/exporter/db"
 	"github.com/odonate/postgres-exporter/exporter/logging"
 	"github.com/prometheus/client_golang/prometheus"
@@ -12,30 +14,151 @@ const (
 	namespace   = "pg_stat"
 	namespaceIO = "pg_statio"
 
-	activitySubSystem    = "activity"
-	locksSubSystem       = "locks"
-	statementsSubSystem  = "statements"
-	userTablesSubSystem  = "user_tables"
-	userIndexesSubSystem = "user_indexes"
+	activitySubSystem         = "activity"
+	databaseSubSystem         = "database"
+	locksSubSystem            = "locks"
+	statementsSubSystem       = "statements"
+	userTablesSubSystem       = "user_tables"
+	userIndexesSubSystem      = "user_indexes"
+	bgWriterSubSystem         = "bgwriter"
+	replicationSubSystem      = "replication"
+	replicationSlotsSubSystem = "replication_slots"
+	archiverSubSystem         = "archiver"
+	walSubSystem              = "wal"
+	progressSubSystem         = "progress"
+	wraparoundSubSystem       = "wraparound"
+	sizeSubSystem             = "size"
+	bloatSubSystem            = "bloat"
+	lockWaitsSubSystem        = "lock_waits"
 )
 
 // Collector wraps the prometheus.Collector.
 type Collector interface {
 	prometheus.Collector
-	// Scrape is used by our exporter to scrape data from postgres.
-	Scrape(ch chan<- prometheus.Metric) error
+	// Scrape is used by our exporter to scrape data from postgres, within the deadline of the context.
+	Scrape(ctx context.Context, ch chan<- prometheus.Metric) error
+}
+
+// Factory instantiates a Collector scraping the given db clients.
+type Factory func(dbClients []*db.Client) Collector
+
+// optsFactory instantiates a Collector scraping the given db clients, tuned by the opts.
+type optsFactory func(dbClients []*db.Client, opts Opts) Collector
+
+// factories maps collector names, as used in configuration files, to their factories.
+var factories = map[string]optsFactory{
+	"pg_bloat":               func(dbClients []*db.Client, opts Opts) Collector { return NewPgBloatCollector(dbClients, opts) },
+	"pg_lock_waits":          func(dbClients []*db.Client, opts Opts) Collector { return NewPgLockWaitsCollector(dbClients, opts) },
+	"pg_locks":               func(dbClients []*db.Client, _ Opts) Collector { return NewPgLocksCollector(dbClients) },
+	"pg_replication_slots":   func(dbClients []*db.Client, _ Opts) Collector { return NewPgReplicationSlotsCollector(dbClients) },
+	"pg_size":                func(dbClients []*db.Client, opts Opts) Collector { return NewPgSizeCollector(dbClients, opts) },
+	"pg_stat_activity":       func(dbClients []*db.Client, _ Opts) Collector { return NewPgStatActivityCollector(dbClients) },
+	"pg_stat_bgwriter":       func(dbClients []*db.Client, _ Opts) Collector { return NewPgStatBgWriterCollector(dbClients) },
+	"pg_stat_database":       func(dbClients []*db.Client, _ Opts) Collector { return NewPgStatDatabaseCollector(dbClients) },
+	"pg_stat_progress":       func(dbClients []*db.Client, _ Opts) Collector { return NewPgStatProgressCollector(dbClients) },
+	"pg_stat_replication":    func(dbClients []*db.Client, _ Opts) Collector { return NewPgStatReplicationCollector(dbClients) },
+	"pg_stat_statements":     func(dbClients []*db.Client, _ Opts) Collector { return NewPgStatStatementsCollector(dbClients) },
+	"pg_stat_user_indexes":   func(dbClients []*db.Client, _ Opts) Collector { return NewPgStatUserIndexesCollector(dbClients) },
+	"pg_stat_user_tables":    func(dbClients []*db.Client, _ Opts) Collector { return NewPgStatUserTableCollector(dbClients) },
+	"pg_stat_wal":            func(dbClients []*db.Client, _ Opts) Collector { return NewPgStatWalCollector(dbClients) },
+	"pg_statio_user_indexes": func(dbClients []*db.Client, _ Opts) Collector { return NewPgStatIOUserIndexesCollector(dbClients) },
+	"pg_statio_user_tables":  func(dbClients []*db.Client, _ Opts) Collector { return NewPgStatIOUserTableCollector(dbClients) },
+	"pg_wraparound":          func(dbClients []*db.Client, opts Opts) Collector { return NewPgWraparoundCollector(dbClients, opts) },
+}
+
+// LabelNames returns the names of the variable labels of the metrics of the collectors,
+// which the labels of targets must not collide with.
+func LabelNames() []string {
+	return []string{
+		"application_name",
+		"client_addr",
+		"command",
+		"database",
+		"datname",
+		"holder",
+		"id",
+		"indexrelname",
+		"last_archived_wal",
+		"last_failed_wal",
+		"locktype",
+		"mode",
+		"phase",
+		"pid",
+		"plugin",
+		"query",
+		"query_fingerprint",
+		"queryid",
+		"relname",
+		"rolname",
+		"schemaname",
+		"sender_host",
+		"slot_name",
+		"slot_type",
+		"state",
+		"status",
+		"sync_state",
+		"usename",
+		"wal_status",
+	}
+}
+
+// DefaultNames specifies the names of the default collectors.
+func DefaultNames() []string {
+	return []string{
+		"pg_stat_activity",
+		"pg_locks",
+		"pg_lock_waits",
+		"pg_stat_database",
+		"pg_stat_progress",
+		"pg_stat_bgwriter",
+		"pg_stat_replication",
+		"pg_stat_wal",
+		"pg_wraparound",
+		"pg_replication_slots",
+		// Statement scrapes take way too long.
+		// "pg_stat_statements",
+		"pg_stat_user_tables",
+		"pg_stat_user_indexes",
+		"pg_statio_user_tables",
+		"pg_statio_user_indexes",
+	}
+}
+
+// RegisterFactory makes a custom collector available under the given name.
+func RegisterFactory(name string, factory Factory) {
+	factories[name] = func(dbClients []*db.Client, _ Opts) Collector { return factory(dbClients) }
+}
+
+// LookupFactory returns the factory of the named collector, tuned by the opts.
+func LookupFactory(name string, opts Opts) (Factory, bool) {
+	factory, ok := factories[name]
+	if !ok {
+		return nil, false
+	}
+	return func(dbClients []*db.Client) Collector { return factory(dbClients, opts) }, true
+}
+
+// DefaultFactories specifies the factories of the default collectors, with the default opts.
+func DefaultFactories() []Factory {
+	names := DefaultNames()
+	defaultFactories := make([]Factory, 0, len(names))
+	for _, name := range names {
+		factory, _ := LookupFactory(name, DefaultOpts())
+		defaultFactories = append(defaultFactories, factory)
+	}
+	return defaultFactories
 }
 
 // DefaultCollectors specifies the list of default collectors.
 func DefaultCollectors(dbClients []*db.Client) []Collector {
-	return []Collector{
-		NewPgStatActivityCollector(dbClients),
-		NewPgLocksCollector(dbClients),
-		// Statement scrapes take way too long.
-		// NewPgStatStatementsCollector(dbClients),
-		NewPgStatUserTableCollector(dbClients),
-		NewPgStatUserIndexesCollector(dbClients),
-		NewPgStatIOUserTableCollector(dbClients),
-		NewPgStatIOUserIndexesCollector(dbClients),
+	return NewCollectors(dbClients, DefaultFactories()...)
+}
+
+// NewCollectors instantiates a collector from every factory.
+func NewCollectors(dbClients []*db.Client, factories ...Factory) []Collector {
+	collectors := make([]Collector, 0, len(factories))
+	for _, factory := range factories {
+		collectors = append(collectors, factory(dbClients))
 	}
+	return collectors
 }
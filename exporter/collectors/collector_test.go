@@ -0,0 +1,34 @@
+package collectors
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var variableLabelsPattern = regexp.MustCompile(`variableLabels: \[([^\]]*)\]`)
+
+func TestLabelNames(t *testing.T) {
+	labelNames := make(map[string]struct{}, len(LabelNames()))
+	for _, name := range LabelNames() {
+		labelNames[name] = struct{}{}
+	}
+	for name, factory := range factories {
+		ch := make(chan *prometheus.Desc, 100)
+		factory(nil, DefaultOpts()).Describe(ch)
+		close(ch)
+		for desc := range ch {
+			match := variableLabelsPattern.FindStringSubmatch(desc.String())
+			if match == nil {
+				t.Fatalf("%s: no variable labels in %s", name, desc)
+			}
+			for _, label := range strings.Fields(match[1]) {
+				if _, ok := labelNames[label]; !ok {
+					t.Errorf("%s: label %q of %s is missing from LabelNames", name, label, desc)
+				}
+			}
+		}
+	}
+}
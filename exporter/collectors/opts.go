@@ -0,0 +1,20 @@
+package collectors
+
+// Opts tune what the collectors of a target export. Unlike db.Opts, changing them on reload
+// keeps the connections of the target.
+type Opts struct {
+	WraparoundRelations int  `long:"wraparound_relations" env:"WRAPAROUND_RELATIONS" default:"10" description:"Number of relations with the oldest unfrozen transaction IDs to export per database (0 is none)" yaml:"wraparound_relations" toml:"wraparound_relations"`
+	SizeRelations       int  `long:"size_relations" env:"SIZE_RELATIONS" default:"100" description:"Number of the largest relations to export the size of per database (0 is none)" yaml:"size_relations" toml:"size_relations"`
+	SizeMinBytes        int  `long:"size_min_bytes" env:"SIZE_MIN_BYTES" default:"0" description:"Total size in bytes below which relations are not exported by the size collector" yaml:"size_min_bytes" toml:"size_min_bytes"`
+	BloatRelations      int  `long:"bloat_relations" env:"BLOAT_RELATIONS" default:"50" description:"Number of the most bloated tables and indexes to export the bloat of per database (0 is none)" yaml:"bloat_relations" toml:"bloat_relations"`
+	LockWaitsRootInfo   bool `long:"lock_waits_root_info" env:"LOCK_WAITS_ROOT_INFO" description:"Export an info series per backend at the root of a blocking chain" yaml:"lock_waits_root_info" toml:"lock_waits_root_info"`
+}
+
+// DefaultOpts returns the opts the collectors default to, matching the defaults of their flags.
+func DefaultOpts() Opts {
+	return Opts{
+		WraparoundRelations: 10,
+		SizeRelations:       100,
+		BloatRelations:      50,
+	}
+}
@@ -0,0 +1,146 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/odonate/postgres-exporter/exporter/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+// PgBloatCollector collects the estimated bloat of the most bloated tables and btree indexes,
+// and that measured by pgstattuple_approx where the extension is installed.
+// Estimating bloat is expensive, so it is meant to be scraped in the background on a long interval.
+type PgBloatCollector struct {
+	dbClients []*db.Client
+	// relations is the number of the most bloated tables and indexes to export.
+	relations int
+
+	tableBloatBytes       *prometheus.Desc
+	tableBloatRatio       *prometheus.Desc
+	indexBloatBytes       *prometheus.Desc
+	indexBloatRatio       *prometheus.Desc
+	tableApproxBloatBytes *prometheus.Desc
+	tableApproxBloatRatio *prometheus.Desc
+}
+
+// NewPgBloatCollector instantiates and returns a new PgBloatCollector.
+func NewPgBloatCollector(dbClients []*db.Client, opts Opts) *PgBloatCollector {
+	tableLabels := []string{"database", "schemaname", "relname"}
+	indexLabels := []string{"database", "schemaname", "relname", "indexrelname"}
+	return &PgBloatCollector{
+		dbClients: dbClients,
+		relations: opts.BloatRelations,
+
+		tableBloatBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, bloatSubSystem, "table_bytes"),
+			"Estimated bytes of the table, including its TOAST table, beyond what its live rows need given its fillfactor",
+			tableLabels,
+			nil,
+		),
+		tableBloatRatio: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, bloatSubSystem, "table_ratio"),
+			"Estimated fraction of the table, including its TOAST table, beyond what its live rows need given its fillfactor",
+			tableLabels,
+			nil,
+		),
+		indexBloatBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, bloatSubSystem, "index_bytes"),
+			"Estimated bytes of the btree index beyond what its entries need given its fillfactor",
+			indexLabels,
+			nil,
+		),
+		indexBloatRatio: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, bloatSubSystem, "index_ratio"),
+			"Estimated fraction of the btree index beyond what its entries need given its fillfactor",
+			indexLabels,
+			nil,
+		),
+		tableApproxBloatBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, bloatSubSystem, "table_approx_bytes"),
+			"Bytes of dead tuples and free space of the table, as measured by pgstattuple_approx",
+			tableLabels,
+			nil,
+		),
+		tableApproxBloatRatio: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, bloatSubSystem, "table_approx_ratio"),
+			"Fraction of the table taken by dead tuples and free space, as measured by pgstattuple_approx",
+			tableLabels,
+			nil,
+		),
+	}
+}
+
+// Describe implements the prometheus.Collector.
+func (c *PgBloatCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.tableBloatBytes
+	ch <- c.tableBloatRatio
+	ch <- c.indexBloatBytes
+	ch <- c.indexBloatRatio
+	ch <- c.tableApproxBloatBytes
+	ch <- c.tableApproxBloatRatio
+}
+
+// Collect implements the promtheus.Collector.
+func (c *PgBloatCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.Scrape(context.Background(), ch)
+}
+
+// Scrape implements our Scraper interface.
+func (c *PgBloatCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
+	start := time.Now()
+	defer func() {
+		log.Infof("bloat scrape took %dms", time.Now().Sub(start).Milliseconds())
+	}()
+	group := errgroup.Group{}
+	for _, dbClient := range c.dbClients {
+		dbClient := dbClient
+		group.Go(func() error { return c.scrape(ctx, dbClient, ch) })
+	}
+	if err := group.Wait(); err != nil {
+		return fmt.Errorf("scraping: %w", err)
+	}
+	return nil
+}
+
+func (c *PgBloatCollector) scrape(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	tableBloats, err := dbClient.SelectPgTableBloat(ctx, c.relations)
+	if err != nil {
+		return fmt.Errorf("table bloat: %w", err)
+	}
+	indexBloats, err := dbClient.SelectPgIndexBloat(ctx, c.relations)
+	if err != nil {
+		return fmt.Errorf("index bloat: %w", err)
+	}
+	relids := make([]int64, 0, len(tableBloats))
+	for _, bloat := range tableBloats {
+		relids = append(relids, bloat.RelID)
+		ch <- prometheus.MustNewConstMetric(c.tableBloatBytes, prometheus.GaugeValue, bloat.BloatBytes, bloat.Database, bloat.SchemaName, bloat.RelName)
+		ch <- prometheus.MustNewConstMetric(c.tableBloatRatio, prometheus.GaugeValue, bloat.BloatRatio, bloat.Database, bloat.SchemaName, bloat.RelName)
+	}
+	for _, bloat := range indexBloats {
+		ch <- prometheus.MustNewConstMetric(c.indexBloatBytes, prometheus.GaugeValue, bloat.BloatBytes, bloat.Database, bloat.SchemaName, bloat.RelName, bloat.IndexRelName)
+		ch <- prometheus.MustNewConstMetric(c.indexBloatRatio, prometheus.GaugeValue, bloat.BloatRatio, bloat.Database, bloat.SchemaName, bloat.RelName, bloat.IndexRelName)
+	}
+	installed, err := dbClient.LookupExtension(ctx, "pgstattuple")
+	if err != nil {
+		return fmt.Errorf("approximate table bloat: %w", err)
+	}
+	if !installed {
+		return nil
+	}
+	// Measure the bloat of the most bloated tables only, as pgstattuple_approx still scans their pages.
+	approxBloats, err := dbClient.SelectPgTableBloatApprox(ctx, relids)
+	if err != nil {
+		// The extension may have been dropped, in which case the next scrapes skip it.
+		_ = dbClient.DetectExtensions(ctx)
+		return fmt.Errorf("approximate table bloat: %w", err)
+	}
+	for _, bloat := range approxBloats {
+		ch <- prometheus.MustNewConstMetric(c.tableApproxBloatBytes, prometheus.GaugeValue, bloat.BloatBytes, bloat.Database, bloat.SchemaName, bloat.RelName)
+		ch <- prometheus.MustNewConstMetric(c.tableApproxBloatRatio, prometheus.GaugeValue, bloat.BloatRatio, bloat.Database, bloat.SchemaName, bloat.RelName)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/odonate/postgres-exporter/exporter/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+// PgLockWaitsCollector collects the backends waiting for locks from pg_locks, and the chains of backends
+// blocking them from pg_blocking_pids().
+type PgLockWaitsCollector struct {
+	dbClients []*db.Client
+	// rootInfo is whether to export an info series per root blocker.
+	rootInfo bool
+
+	waitingBackends *prometheus.Desc
+	maxWaitSeconds  *prometheus.Desc
+	rootBlockers    *prometheus.Desc
+	maxChainDepth   *prometheus.Desc
+	rootBlockerInfo *prometheus.Desc
+}
+
+// NewPgLockWaitsCollector instantiates and returns a new PgLockWaitsCollector.
+func NewPgLockWaitsCollector(dbClients []*db.Client, opts Opts) *PgLockWaitsCollector {
+	variableLabels := []string{"database", "datname", "locktype", "schemaname", "relname"}
+	return &PgLockWaitsCollector{
+		dbClients: dbClients,
+		rootInfo:  opts.LockWaitsRootInfo,
+
+		waitingBackends: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, lockWaitsSubSystem, "waiting_backends"),
+			"Number of backends waiting for a lock",
+			variableLabels,
+			nil,
+		),
+		maxWaitSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, lockWaitsSubSystem, "max_wait_seconds"),
+			"Longest time a backend has been waiting for a lock (since the start of its statement before PG14)",
+			variableLabels,
+			nil,
+		),
+		rootBlockers: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, lockWaitsSubSystem, "root_blockers"),
+			"Number of distinct backends at the root of the chains blocking the waiting backends, which do not wait themselves",
+			variableLabels,
+			nil,
+		),
+		maxChainDepth: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, lockWaitsSubSystem, "max_chain_depth"),
+			"Depth of the longest chain of backends blocking a waiting backend, 1 if it is blocked by a root directly",
+			variableLabels,
+			nil,
+		),
+		rootBlockerInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, lockWaitsSubSystem, "root_blocker_info"),
+			"Backend at the root of a blocking chain, always 1",
+			[]string{"database", "pid", "datname", "application_name", "usename", "state", "query_fingerprint"},
+			nil,
+		),
+	}
+}
+
+// Describe implements the prometheus.Collector.
+func (c *PgLockWaitsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.waitingBackends
+	ch <- c.maxWaitSeconds
+	ch <- c.rootBlockers
+	ch <- c.maxChainDepth
+	ch <- c.rootBlockerInfo
+}
+
+// Collect implements the promtheus.Collector.
+func (c *PgLockWaitsCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.Scrape(context.Background(), ch)
+}
+
+// Scrape implements our Scraper interface.
+func (c *PgLockWaitsCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
+	start := time.Now()
+	defer func() {
+		log.Infof("lock waits scrape took %dms", time.Now().Sub(start).Milliseconds())
+	}()
+	group := errgroup.Group{}
+	for _, dbClient := range c.dbClients {
+		dbClient := dbClient
+		group.Go(func() error { return c.scrapeWaits(ctx, dbClient, ch) })
+		if c.rootInfo {
+			group.Go(func() error { return c.scrapeRootBlockers(ctx, dbClient, ch) })
+		}
+	}
+	if err := group.Wait(); err != nil {
+		return fmt.Errorf("scraping: %w", err)
+	}
+	return nil
+}
+
+func (c *PgLockWaitsCollector) scrapeWaits(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	lockWaits, err := dbClient.SelectPgLockWaits(ctx)
+	if err != nil {
+		return fmt.Errorf("lock waits: %w", err)
+	}
+	for _, wait := range lockWaits {
+		labels := []string{wait.Database, wait.DatName, wait.LockType, wait.SchemaName, wait.RelName}
+		ch <- prometheus.MustNewConstMetric(c.waitingBackends, prometheus.GaugeValue, float64(wait.Waiting), labels...)
+		// Null if the backends are not visible in pg_stat_activity.
+		if wait.MaxWaitSeconds != nil {
+			ch <- prometheus.MustNewConstMetric(c.maxWaitSeconds, prometheus.GaugeValue, *wait.MaxWaitSeconds, labels...)
+		}
+		ch <- prometheus.MustNewConstMetric(c.rootBlockers, prometheus.GaugeValue, float64(wait.RootBlockers), labels...)
+		ch <- prometheus.MustNewConstMetric(c.maxChainDepth, prometheus.GaugeValue, float64(wait.MaxChainDepth), labels...)
+	}
+	return nil
+}
+
+func (c *PgLockWaitsCollector) scrapeRootBlockers(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	rootBlockers, err := dbClient.SelectPgLockBlockingRoots(ctx)
+	if err != nil {
+		return fmt.Errorf("root blockers: %w", err)
+	}
+	for _, root := range rootBlockers {
+		ch <- prometheus.MustNewConstMetric(c.rootBlockerInfo, prometheus.GaugeValue, 1,
+			root.Database, strconv.Itoa(root.Pid), root.DatName, root.ApplicationName, root.UseName, root.State, root.QueryFingerprint)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/odonate/postgres-exporter/exporter/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+// walStatuses are the values of pg_replication_slots.wal_status, exported as a state set.
+var walStatuses = []string{"reserved", "extended", "unreserved", "lost"}
+
+// PgReplicationSlotsCollector collects from pg_replication_slots.
+type PgReplicationSlotsCollector struct {
+	dbClients []*db.Client
+
+	info                   *prometheus.Desc
+	active                 *prometheus.Desc
+	temporary              *prometheus.Desc
+	retainedWalBytes       *prometheus.Desc
+	confirmedFlushLagBytes *prometheus.Desc
+	walStatus              *prometheus.Desc
+	safeWalSizeBytes       *prometheus.Desc
+}
+
+// NewPgReplicationSlotsCollector instantiates and returns a new PgReplicationSlotsCollector.
+func NewPgReplicationSlotsCollector(dbClients []*db.Client) *PgReplicationSlotsCollector {
+	variableLabels := []string{"database", "slot_name"}
+	return &PgReplicationSlotsCollector{
+		dbClients: dbClients,
+
+		info: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSlotsSubSystem, "info"),
+			"Type, output plugin and database of the slot, always 1",
+			append(variableLabels, "slot_type", "plugin", "datname"),
+			nil,
+		),
+		active: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSlotsSubSystem, "active"),
+			"Whether the slot is currently being used",
+			variableLabels,
+			nil,
+		),
+		temporary: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSlotsSubSystem, "temporary"),
+			"Whether the slot is temporary, i.e. dropped at the end of the session",
+			variableLabels,
+			nil,
+		),
+		retainedWalBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSlotsSubSystem, "retained_wal_bytes"),
+			"Bytes of WAL retained for the slot, from its restart_lsn to the current WAL LSN",
+			variableLabels,
+			nil,
+		),
+		confirmedFlushLagBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSlotsSubSystem, "confirmed_flush_lag_bytes"),
+			"Bytes of WAL not yet confirmed by the consumer of the logical slot",
+			variableLabels,
+			nil,
+		),
+		walStatus: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSlotsSubSystem, "wal_status"),
+			"Availability of the WAL files claimed by the slot, 1 for the current one (PG13+)",
+			append(variableLabels, "wal_status"),
+			nil,
+		),
+		safeWalSizeBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSlotsSubSystem, "safe_wal_size_bytes"),
+			"Bytes of WAL that can be written before the slot is in danger of getting lost (PG13+, with max_slot_wal_keep_size)",
+			variableLabels,
+			nil,
+		),
+	}
+}
+
+// Describe implements the prometheus.Collector.
+func (c *PgReplicationSlotsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.info
+	ch <- c.active
+	ch <- c.temporary
+	ch <- c.retainedWalBytes
+	ch <- c.confirmedFlushLagBytes
+	ch <- c.walStatus
+	ch <- c.safeWalSizeBytes
+}
+
+// Collect implements the promtheus.Collector.
+func (c *PgReplicationSlotsCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.Scrape(context.Background(), ch)
+}
+
+// Scrape implements our Scraper interface.
+func (c *PgReplicationSlotsCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
+	start := time.Now()
+	defer func() {
+		log.Infof("replication slots scrape took %dms", time.Now().Sub(start).Milliseconds())
+	}()
+	group := errgroup.Group{}
+	for _, dbClient := range c.dbClients {
+		dbClient := dbClient
+		group.Go(func() error { return c.scrape(ctx, dbClient, ch) })
+	}
+	if err := group.Wait(); err != nil {
+		return fmt.Errorf("scraping: %w", err)
+	}
+	return nil
+}
+
+func (c *PgReplicationSlotsCollector) scrape(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	slots, err := dbClient.SelectPgReplicationSlots(ctx)
+	if err != nil {
+		return fmt.Errorf("replication slot stats: %w", err)
+	}
+	for _, slot := range slots {
+		ch <- prometheus.MustNewConstMetric(c.info, prometheus.GaugeValue, 1, slot.Database, slot.SlotName, slot.SlotType, slot.Plugin, slot.DatName)
+		ch <- prometheus.MustNewConstMetric(c.active, prometheus.GaugeValue, boolValue(slot.Active), slot.Database, slot.SlotName)
+		ch <- prometheus.MustNewConstMetric(c.temporary, prometheus.GaugeValue, boolValue(slot.Temporary), slot.Database, slot.SlotName)
+		// Null for slots that never reserved WAL.
+		if slot.RetainedWalBytes != nil {
+			ch <- prometheus.MustNewConstMetric(c.retainedWalBytes, prometheus.GaugeValue, *slot.RetainedWalBytes, slot.Database, slot.SlotName)
+		}
+		if slot.ConfirmedFlushLagBytes != nil {
+			ch <- prometheus.MustNewConstMetric(c.confirmedFlushLagBytes, prometheus.GaugeValue, *slot.ConfirmedFlushLagBytes, slot.Database, slot.SlotName)
+		}
+		if slot.WalStatus != nil {
+			for _, walStatus := range walStatuses {
+				ch <- prometheus.MustNewConstMetric(c.walStatus, prometheus.GaugeValue, boolValue(*slot.WalStatus == walStatus), slot.Database, slot.SlotName, walStatus)
+			}
+		}
+		if slot.SafeWalSize != nil {
+			ch <- prometheus.MustNewConstMetric(c.safeWalSizeBytes, prometheus.GaugeValue, *slot.SafeWalSize, slot.Database, slot.SlotName)
+		}
+	}
+	return nil
+}
+
+// boolValue returns 1 if b, else 0.
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
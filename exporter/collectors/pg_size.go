@@ -0,0 +1,123 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/odonate/postgres-exporter/exporter/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+// PgSizeCollector collects the size of databases, and of the largest relations of the database.
+type PgSizeCollector struct {
+	dbClients []*db.Client
+	// relations is the number of the largest relations to export, of at least minBytes in total.
+	relations int
+	minBytes  int
+
+	databaseBytes      *prometheus.Desc
+	relationHeapBytes  *prometheus.Desc
+	relationIndexBytes *prometheus.Desc
+	relationToastBytes *prometheus.Desc
+	relationTotalBytes *prometheus.Desc
+}
+
+// NewPgSizeCollector instantiates and returns a new PgSizeCollector.
+func NewPgSizeCollector(dbClients []*db.Client, opts Opts) *PgSizeCollector {
+	relationLabels := []string{"database", "schemaname", "relname"}
+	return &PgSizeCollector{
+		dbClients: dbClients,
+		relations: opts.SizeRelations,
+		minBytes:  opts.SizeMinBytes,
+
+		databaseBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, sizeSubSystem, "database_bytes"),
+			"Disk space used by the database",
+			[]string{"database", "datname"},
+			nil,
+		),
+		relationHeapBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, sizeSubSystem, "relation_heap_bytes"),
+			"Disk space used by the heap of the relation, including its free space and visibility maps",
+			relationLabels,
+			nil,
+		),
+		relationIndexBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, sizeSubSystem, "relation_index_bytes"),
+			"Disk space used by the indexes of the relation",
+			relationLabels,
+			nil,
+		),
+		relationToastBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, sizeSubSystem, "relation_toast_bytes"),
+			"Disk space used by the TOAST table of the relation, including its index",
+			relationLabels,
+			nil,
+		),
+		relationTotalBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, sizeSubSystem, "relation_total_bytes"),
+			"Total disk space used by the relation, including its indexes and TOAST table",
+			relationLabels,
+			nil,
+		),
+	}
+}
+
+// Describe implements the prometheus.Collector.
+func (c *PgSizeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.databaseBytes
+	ch <- c.relationHeapBytes
+	ch <- c.relationIndexBytes
+	ch <- c.relationToastBytes
+	ch <- c.relationTotalBytes
+}
+
+// Collect implements the promtheus.Collector.
+func (c *PgSizeCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.Scrape(context.Background(), ch)
+}
+
+// Scrape implements our Scraper interface.
+func (c *PgSizeCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
+	start := time.Now()
+	defer func() {
+		log.Infof("size scrape took %dms", time.Now().Sub(start).Milliseconds())
+	}()
+	group := errgroup.Group{}
+	for _, dbClient := range c.dbClients {
+		dbClient := dbClient
+		group.Go(func() error { return c.scrapeDatabases(ctx, dbClient, ch) })
+		group.Go(func() error { return c.scrapeRelations(ctx, dbClient, ch) })
+	}
+	if err := group.Wait(); err != nil {
+		return fmt.Errorf("scraping: %w", err)
+	}
+	return nil
+}
+
+func (c *PgSizeCollector) scrapeDatabases(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	databaseSizes, err := dbClient.SelectPgDatabaseSize(ctx)
+	if err != nil {
+		return fmt.Errorf("database sizes: %w", err)
+	}
+	for _, size := range databaseSizes {
+		ch <- prometheus.MustNewConstMetric(c.databaseBytes, prometheus.GaugeValue, size.SizeBytes, size.Database, size.DatName)
+	}
+	return nil
+}
+
+func (c *PgSizeCollector) scrapeRelations(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	relationSizes, err := dbClient.SelectPgRelationSize(ctx, c.relations, c.minBytes)
+	if err != nil {
+		return fmt.Errorf("relation sizes: %w", err)
+	}
+	for _, size := range relationSizes {
+		ch <- prometheus.MustNewConstMetric(c.relationHeapBytes, prometheus.GaugeValue, size.HeapBytes, size.Database, size.SchemaName, size.RelName)
+		ch <- prometheus.MustNewConstMetric(c.relationIndexBytes, prometheus.GaugeValue, size.IndexBytes, size.Database, size.SchemaName, size.RelName)
+		ch <- prometheus.MustNewConstMetric(c.relationToastBytes, prometheus.GaugeValue, size.ToastBytes, size.Database, size.SchemaName, size.RelName)
+		ch <- prometheus.MustNewConstMetric(c.relationTotalBytes, prometheus.GaugeValue, size.TotalBytes, size.Database, size.SchemaName, size.RelName)
+	}
+	return nil
+}
@@ -51,11 +51,11 @@ func (c *PgStatActivityCollector) Describe(ch chan<- *prometheus.Desc) {
 func (c *PgStatActivityCollector) Collect(ch chan<- prometheus.Metric) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	_ = c.Scrape(ch)
+	_ = c.Scrape(context.Background(), ch)
 }
 
 // Scrape implements our Scraper interfacc.
-func (c *PgStatActivityCollector) Scrape(ch chan<- prometheus.Metric) error {
+func (c *PgStatActivityCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
 	start := time.Now()
 	defer func() {
 		log.Infof("activity scrape took %dms", time.Now().Sub(start).Milliseconds())
@@ -63,7 +63,7 @@ func (c *PgStatActivityCollector) Scrape(ch chan<- prometheus.Metric) error {
 	group := errgroup.Group{}
 	for _, dbClient := range c.dbClients {
 		dbClient := dbClient
-		group.Go(func() error { return c.scrape(dbClient, ch) })
+		group.Go(func() error { return c.scrape(ctx, dbClient, ch) })
 	}
 	if err := group.Wait(); err != nil {
 		return fmt.Errorf("scraping: %w", err)
@@ -71,8 +71,8 @@ func (c *PgStatActivityCollector) Scrape(ch chan<- prometheus.Metric) error {
 	return nil
 }
 
-func (c *PgStatActivityCollector) scrape(dbClient *db.Client, ch chan<- prometheus.Metric) error {
-	activityStats, err := dbClient.SelectPgStatActivity(context.Background())
+func (c *PgStatActivityCollector) scrape(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	activityStats, err := dbClient.SelectPgStatActivity(ctx)
 	if err != nil {
 		return fmt.Errorf("activity stats: %w", err)
 	}
@@ -0,0 +1,152 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/odonate/postgres-exporter/exporter/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+// PgStatBgWriterCollector collects from pg_stat_bgwriter, and pg_stat_checkpointer from PG17.
+type PgStatBgWriterCollector struct {
+	dbClients []*db.Client
+
+	checkpointsTimed           *prometheus.Desc
+	checkpointsReq             *prometheus.Desc
+	checkpointWriteTimeSeconds *prometheus.Desc
+	checkpointSyncTimeSeconds  *prometheus.Desc
+	buffersCheckpoint          *prometheus.Desc
+	buffersClean               *prometheus.Desc
+	maxWrittenClean            *prometheus.Desc
+	buffersBackend             *prometheus.Desc
+	buffersBackendFsync        *prometheus.Desc
+	buffersAlloc               *prometheus.Desc
+}
+
+// NewPgStatBgWriterCollector instantiates and returns a new PgStatBgWriterCollector.
+func NewPgStatBgWriterCollector(dbClients []*db.Client) *PgStatBgWriterCollector {
+	variableLabels := []string{"database"}
+	return &PgStatBgWriterCollector{
+		dbClients: dbClients,
+
+		checkpointsTimed: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, bgWriterSubSystem, "checkpoints_timed"),
+			"Number of scheduled checkpoints that have been performed",
+			variableLabels,
+			nil,
+		),
+		checkpointsReq: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, bgWriterSubSystem, "checkpoints_req"),
+			"Number of requested checkpoints that have been performed",
+			variableLabels,
+			nil,
+		),
+		checkpointWriteTimeSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, bgWriterSubSystem, "checkpoint_write_time_seconds"),
+			"Time spent in the portion of checkpoint processing where files are written to disk, in seconds",
+			variableLabels,
+			nil,
+		),
+		checkpointSyncTimeSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, bgWriterSubSystem, "checkpoint_sync_time_seconds"),
+			"Time spent in the portion of checkpoint processing where files are synchronized to disk, in seconds",
+			variableLabels,
+			nil,
+		),
+		buffersCheckpoint: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, bgWriterSubSystem, "buffers_checkpoint"),
+			"Number of buffers written during checkpoints",
+			variableLabels,
+			nil,
+		),
+		buffersClean: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, bgWriterSubSystem, "buffers_clean"),
+			"Number of buffers written by the background writer",
+			variableLabels,
+			nil,
+		),
+		maxWrittenClean: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, bgWriterSubSystem, "maxwritten_clean"),
+			"Number of times the background writer stopped a cleaning scan because it had written too many buffers",
+			variableLabels,
+			nil,
+		),
+		buffersBackend: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, bgWriterSubSystem, "buffers_backend"),
+			"Number of buffers written directly by a backend",
+			variableLabels,
+			nil,
+		),
+		buffersBackendFsync: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, bgWriterSubSystem, "buffers_backend_fsync"),
+			"Number of times a backend had to execute its own fsync call",
+			variableLabels,
+			nil,
+		),
+		buffersAlloc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, bgWriterSubSystem, "buffers_alloc"),
+			"Number of buffers allocated",
+			variableLabels,
+			nil,
+		),
+	}
+}
+
+// Describe implements the prometheus.Collector.
+func (c *PgStatBgWriterCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.checkpointsTimed
+	ch <- c.checkpointsReq
+	ch <- c.checkpointWriteTimeSeconds
+	ch <- c.checkpointSyncTimeSeconds
+	ch <- c.buffersCheckpoint
+	ch <- c.buffersClean
+	ch <- c.maxWrittenClean
+	ch <- c.buffersBackend
+	ch <- c.buffersBackendFsync
+	ch <- c.buffersAlloc
+}
+
+// Collect implements the promtheus.Collector.
+func (c *PgStatBgWriterCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.Scrape(context.Background(), ch)
+}
+
+// Scrape implements our Scraper interface.
+func (c *PgStatBgWriterCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
+	start := time.Now()
+	defer func() {
+		log.Infof("bgwriter scrape took %dms", time.Now().Sub(start).Milliseconds())
+	}()
+	group := errgroup.Group{}
+	for _, dbClient := range c.dbClients {
+		dbClient := dbClient
+		group.Go(func() error { return c.scrape(ctx, dbClient, ch) })
+	}
+	if err := group.Wait(); err != nil {
+		return fmt.Errorf("scraping: %w", err)
+	}
+	return nil
+}
+
+func (c *PgStatBgWriterCollector) scrape(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	stats, err := dbClient.SelectPgStatBgWriter(ctx)
+	if err != nil {
+		return fmt.Errorf("bgwriter stats: %w", err)
+	}
+	for _, stat := range stats {
+		ch <- prometheus.MustNewConstMetric(c.checkpointsTimed, prometheus.CounterValue, float64(stat.CheckpointsTimed), stat.Database)
+		ch <- prometheus.MustNewConstMetric(c.checkpointsReq, prometheus.CounterValue, float64(stat.CheckpointsReq), stat.Database)
+		ch <- prometheus.MustNewConstMetric(c.checkpointWriteTimeSeconds, prometheus.CounterValue, stat.CheckpointWriteTimeSeconds, stat.Database)
+		ch <- prometheus.MustNewConstMetric(c.checkpointSyncTimeSeconds, prometheus.CounterValue, stat.CheckpointSyncTimeSeconds, stat.Database)
+		ch <- prometheus.MustNewConstMetric(c.buffersCheckpoint, prometheus.CounterValue, float64(stat.BuffersCheckpoint), stat.Database)
+		ch <- prometheus.MustNewConstMetric(c.buffersClean, prometheus.CounterValue, float64(stat.BuffersClean), stat.Database)
+		ch <- prometheus.MustNewConstMetric(c.maxWrittenClean, prometheus.CounterValue, float64(stat.MaxWrittenClean), stat.Database)
+		ch <- prometheus.MustNewConstMetric(c.buffersBackend, prometheus.CounterValue, float64(stat.BuffersBackend), stat.Database)
+		ch <- prometheus.MustNewConstMetric(c.buffersBackendFsync, prometheus.CounterValue, float64(stat.BuffersBackendFsync), stat.Database)
+		ch <- prometheus.MustNewConstMetric(c.buffersAlloc, prometheus.CounterValue, float64(stat.BuffersAlloc), stat.Database)
+	}
+	return nil
+}
@@ -0,0 +1,303 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/odonate/postgres-exporter/exporter/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+// PgStatDatabaseCollector collects from pg_stat_database.
+type PgStatDatabaseCollector struct {
+	dbClients                    []*db.Client
+	numBackends                  *prometheus.Desc
+	xactCommit                   *prometheus.Desc
+	xactRollback                 *prometheus.Desc
+	blksRead                     *prometheus.Desc
+	blksHit                      *prometheus.Desc
+	tupReturned                  *prometheus.Desc
+	tupFetched                   *prometheus.Desc
+	tupInserted                  *prometheus.Desc
+	tupUpdated                   *prometheus.Desc
+	tupDeleted                   *prometheus.Desc
+	conflicts                    *prometheus.Desc
+	tempFiles                    *prometheus.Desc
+	tempBytes                    *prometheus.Desc
+	deadlocks                    *prometheus.Desc
+	checksumFailures             *prometheus.Desc
+	blkReadTimeSeconds           *prometheus.Desc
+	blkWriteTimeSeconds          *prometheus.Desc
+	sessionTimeSeconds           *prometheus.Desc
+	activeTimeSeconds            *prometheus.Desc
+	idleInTransactionTimeSeconds *prometheus.Desc
+	sessions                     *prometheus.Desc
+	sessionsAbandoned            *prometheus.Desc
+	sessionsFatal                *prometheus.Desc
+	sessionsKilled               *prometheus.Desc
+	statsReset                   *prometheus.Desc
+}
+
+// NewPgStatDatabaseCollector instantiates and returns a new PgStatDatabaseCollector.
+func NewPgStatDatabaseCollector(dbClients []*db.Client) *PgStatDatabaseCollector {
+	variableLabels := []string{"database", "datname"}
+	return &PgStatDatabaseCollector{
+		dbClients: dbClients,
+		numBackends: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "numbackends"),
+			"Number of backends currently connected to this database",
+			variableLabels,
+			nil,
+		),
+		xactCommit: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "xact_commit"),
+			"Number of transactions in this database that have been committed",
+			variableLabels,
+			nil,
+		),
+		xactRollback: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "xact_rollback"),
+			"Number of transactions in this database that have been rolled back",
+			variableLabels,
+			nil,
+		),
+		blksRead: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "blks_read"),
+			"Number of disk blocks read in this database",
+			variableLabels,
+			nil,
+		),
+		blksHit: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "blks_hit"),
+			"Number of times disk blocks were found already in the buffer cache",
+			variableLabels,
+			nil,
+		),
+		tupReturned: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "tup_returned"),
+			"Number of live rows fetched by sequential scans and index entries returned by index scans",
+			variableLabels,
+			nil,
+		),
+		tupFetched: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "tup_fetched"),
+			"Number of live rows fetched by index scans",
+			variableLabels,
+			nil,
+		),
+		tupInserted: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "tup_inserted"),
+			"Number of rows inserted by queries in this database",
+			variableLabels,
+			nil,
+		),
+		tupUpdated: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "tup_updated"),
+			"Number of rows updated by queries in this database",
+			variableLabels,
+			nil,
+		),
+		tupDeleted: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "tup_deleted"),
+			"Number of rows deleted by queries in this database",
+			variableLabels,
+			nil,
+		),
+		conflicts: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "conflicts"),
+			"Number of queries canceled due to conflicts with recovery in this database",
+			variableLabels,
+			nil,
+		),
+		tempFiles: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "temp_files"),
+			"Number of temporary files created by queries in this database",
+			variableLabels,
+			nil,
+		),
+		tempBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "temp_bytes"),
+			"Total amount of data written to temporary files by queries in this database",
+			variableLabels,
+			nil,
+		),
+		deadlocks: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "deadlocks"),
+			"Number of deadlocks detected in this database",
+			variableLabels,
+			nil,
+		),
+		checksumFailures: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "checksum_failures"),
+			"Number of data page checksum failures detected in this database (PG12+, with data checksums enabled)",
+			variableLabels,
+			nil,
+		),
+		blkReadTimeSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "blk_read_time_seconds"),
+			"Time spent reading data file blocks by backends in this database, in seconds",
+			variableLabels,
+			nil,
+		),
+		blkWriteTimeSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "blk_write_time_seconds"),
+			"Time spent writing data file blocks by backends in this database, in seconds",
+			variableLabels,
+			nil,
+		),
+		sessionTimeSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "session_time_seconds"),
+			"Time spent by database sessions in this database, in seconds (PG14+)",
+			variableLabels,
+			nil,
+		),
+		activeTimeSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "active_time_seconds"),
+			"Time spent executing SQL statements in this database, in seconds (PG14+)",
+			variableLabels,
+			nil,
+		),
+		idleInTransactionTimeSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "idle_in_transaction_time_seconds"),
+			"Time spent idling while in a transaction in this database, in seconds (PG14+)",
+			variableLabels,
+			nil,
+		),
+		sessions: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "sessions"),
+			"Total number of sessions established to this database (PG14+)",
+			variableLabels,
+			nil,
+		),
+		sessionsAbandoned: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "sessions_abandoned"),
+			"Number of database sessions to this database that were terminated because connection to the client was lost (PG14+)",
+			variableLabels,
+			nil,
+		),
+		sessionsFatal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "sessions_fatal"),
+			"Number of database sessions to this database that were terminated by fatal errors (PG14+)",
+			variableLabels,
+			nil,
+		),
+		sessionsKilled: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "sessions_killed"),
+			"Number of database sessions to this database that were terminated by operator intervention (PG14+)",
+			variableLabels,
+			nil,
+		),
+		statsReset: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, databaseSubSystem, "stats_reset_timestamp_seconds"),
+			"Time at which these statistics were last reset, in seconds since the epoch",
+			variableLabels,
+			nil,
+		),
+	}
+}
+
+// Describe implements the prometheus.Collector.
+func (c *PgStatDatabaseCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.numBackends
+	ch <- c.xactCommit
+	ch <- c.xactRollback
+	ch <- c.blksRead
+	ch <- c.blksHit
+	ch <- c.tupReturned
+	ch <- c.tupFetched
+	ch <- c.tupInserted
+	ch <- c.tupUpdated
+	ch <- c.tupDeleted
+	ch <- c.conflicts
+	ch <- c.tempFiles
+	ch <- c.tempBytes
+	ch <- c.deadlocks
+	ch <- c.checksumFailures
+	ch <- c.blkReadTimeSeconds
+	ch <- c.blkWriteTimeSeconds
+	ch <- c.sessionTimeSeconds
+	ch <- c.activeTimeSeconds
+	ch <- c.idleInTransactionTimeSeconds
+	ch <- c.sessions
+	ch <- c.sessionsAbandoned
+	ch <- c.sessionsFatal
+	ch <- c.sessionsKilled
+	ch <- c.statsReset
+}
+
+// Collect implements the promtheus.Collector.
+func (c *PgStatDatabaseCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.Scrape(context.Background(), ch)
+}
+
+// Scrape implements our Scraper interface.
+func (c *PgStatDatabaseCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
+	start := time.Now()
+	defer func() {
+		log.Infof("database scrape took %dms", time.Now().Sub(start).Milliseconds())
+	}()
+	group := errgroup.Group{}
+	for _, dbClient := range c.dbClients {
+		dbClient := dbClient
+		group.Go(func() error { return c.scrape(ctx, dbClient, ch) })
+	}
+	if err := group.Wait(); err != nil {
+		return fmt.Errorf("scraping: %w", err)
+	}
+	return nil
+}
+
+func (c *PgStatDatabaseCollector) scrape(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	databaseStats, err := dbClient.SelectPgStatDatabase(ctx)
+	if err != nil {
+		return fmt.Errorf("database stats: %w", err)
+	}
+	for _, stat := range databaseStats {
+		ch <- prometheus.MustNewConstMetric(c.numBackends, prometheus.GaugeValue, float64(stat.NumBackends), stat.Database, stat.DatName)
+		ch <- prometheus.MustNewConstMetric(c.xactCommit, prometheus.CounterValue, float64(stat.XactCommit), stat.Database, stat.DatName)
+		ch <- prometheus.MustNewConstMetric(c.xactRollback, prometheus.CounterValue, float64(stat.XactRollback), stat.Database, stat.DatName)
+		ch <- prometheus.MustNewConstMetric(c.blksRead, prometheus.CounterValue, float64(stat.BlksRead), stat.Database, stat.DatName)
+		ch <- prometheus.MustNewConstMetric(c.blksHit, prometheus.CounterValue, float64(stat.BlksHit), stat.Database, stat.DatName)
+		ch <- prometheus.MustNewConstMetric(c.tupReturned, prometheus.CounterValue, float64(stat.TupReturned), stat.Database, stat.DatName)
+		ch <- prometheus.MustNewConstMetric(c.tupFetched, prometheus.CounterValue, float64(stat.TupFetched), stat.Database, stat.DatName)
+		ch <- prometheus.MustNewConstMetric(c.tupInserted, prometheus.CounterValue, float64(stat.TupInserted), stat.Database, stat.DatName)
+		ch <- prometheus.MustNewConstMetric(c.tupUpdated, prometheus.CounterValue, float64(stat.TupUpdated), stat.Database, stat.DatName)
+		ch <- prometheus.MustNewConstMetric(c.tupDeleted, prometheus.CounterValue, float64(stat.TupDeleted), stat.Database, stat.DatName)
+		ch <- prometheus.MustNewConstMetric(c.conflicts, prometheus.CounterValue, float64(stat.Conflicts), stat.Database, stat.DatName)
+		ch <- prometheus.MustNewConstMetric(c.tempFiles, prometheus.CounterValue, float64(stat.TempFiles), stat.Database, stat.DatName)
+		ch <- prometheus.MustNewConstMetric(c.tempBytes, prometheus.CounterValue, float64(stat.TempBytes), stat.Database, stat.DatName)
+		ch <- prometheus.MustNewConstMetric(c.deadlocks, prometheus.CounterValue, float64(stat.Deadlocks), stat.Database, stat.DatName)
+		if stat.ChecksumFailures != nil {
+			ch <- prometheus.MustNewConstMetric(c.checksumFailures, prometheus.CounterValue, float64(*stat.ChecksumFailures), stat.Database, stat.DatName)
+		}
+		ch <- prometheus.MustNewConstMetric(c.blkReadTimeSeconds, prometheus.CounterValue, stat.BlkReadTimeSeconds, stat.Database, stat.DatName)
+		ch <- prometheus.MustNewConstMetric(c.blkWriteTimeSeconds, prometheus.CounterValue, stat.BlkWriteTimeSeconds, stat.Database, stat.DatName)
+		if stat.SessionTimeSeconds != nil {
+			ch <- prometheus.MustNewConstMetric(c.sessionTimeSeconds, prometheus.CounterValue, *stat.SessionTimeSeconds, stat.Database, stat.DatName)
+		}
+		if stat.ActiveTimeSeconds != nil {
+			ch <- prometheus.MustNewConstMetric(c.activeTimeSeconds, prometheus.CounterValue, *stat.ActiveTimeSeconds, stat.Database, stat.DatName)
+		}
+		if stat.IdleInTransactionTimeSeconds != nil {
+			ch <- prometheus.MustNewConstMetric(c.idleInTransactionTimeSeconds, prometheus.CounterValue, *stat.IdleInTransactionTimeSeconds, stat.Database, stat.DatName)
+		}
+		if stat.Sessions != nil {
+			ch <- prometheus.MustNewConstMetric(c.sessions, prometheus.CounterValue, float64(*stat.Sessions), stat.Database, stat.DatName)
+		}
+		if stat.SessionsAbandoned != nil {
+			ch <- prometheus.MustNewConstMetric(c.sessionsAbandoned, prometheus.CounterValue, float64(*stat.SessionsAbandoned), stat.Database, stat.DatName)
+		}
+		if stat.SessionsFatal != nil {
+			ch <- prometheus.MustNewConstMetric(c.sessionsFatal, prometheus.CounterValue, float64(*stat.SessionsFatal), stat.Database, stat.DatName)
+		}
+		if stat.SessionsKilled != nil {
+			ch <- prometheus.MustNewConstMetric(c.sessionsKilled, prometheus.CounterValue, float64(*stat.SessionsKilled), stat.Database, stat.DatName)
+		}
+		if stat.StatsReset != nil {
+			ch <- prometheus.MustNewConstMetric(c.statsReset, prometheus.GaugeValue, *stat.StatsReset, stat.Database, stat.DatName)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/odonate/postgres-exporter/exporter/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+// progressPhases are the phases of every pg_stat_progress_* view, exported as a state set.
+// Index builds also report the subphases of the access method, e.g. "building index: scanning table",
+// which are exported as they come.
+var progressPhases = map[string][]string{
+	"vacuum": {
+		"initializing",
+		"scanning heap",
+		"vacuuming indexes",
+		"vacuuming heap",
+		"cleaning up indexes",
+		"truncating heap",
+		"performing final cleanup",
+	},
+	"analyze": {
+		"initializing",
+		"acquiring sample rows",
+		"acquiring inherited sample rows",
+		"computing statistics",
+		"computing extended statistics",
+		"finalizing analyze",
+	},
+	"create_index": {
+		"initializing",
+		"waiting for writers before build",
+		"building index",
+		"waiting for writers before validation",
+		"index validation: scanning index",
+		"index validation: sorting tuples",
+		"index validation: scanning table",
+		"waiting for old snapshots",
+		"waiting for readers before marking dead",
+		"waiting for readers before dropping",
+	},
+	"cluster": {
+		"initializing",
+		"seq scanning heap",
+		"index scanning heap",
+		"sorting tuples",
+		"writing new heap",
+		"swapping relation files",
+		"rebuilding index",
+		"performing final cleanup",
+	},
+}
+
+// PgStatProgressCollector collects from pg_stat_progress_vacuum, and pg_stat_progress_create_index,
+// pg_stat_progress_cluster and pg_stat_progress_analyze where available.
+type PgStatProgressCollector struct {
+	dbClients []*db.Client
+
+	phase          *prometheus.Desc
+	blocksTotal    *prometheus.Desc
+	blocksDone     *prometheus.Desc
+	tuplesTotal    *prometheus.Desc
+	tuplesDone     *prometheus.Desc
+	elapsedSeconds *prometheus.Desc
+	autoVacuum     *prometheus.Desc
+}
+
+// NewPgStatProgressCollector instantiates and returns a new PgStatProgressCollector.
+func NewPgStatProgressCollector(dbClients []*db.Client) *PgStatProgressCollector {
+	variableLabels := []string{"database", "datname", "schemaname", "relname", "pid", "command"}
+	return &PgStatProgressCollector{
+		dbClients: dbClients,
+
+		phase: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, progressSubSystem, "phase"),
+			"Current phase of the command, 1 for the current one",
+			append(variableLabels, "phase"),
+			nil,
+		),
+		blocksTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, progressSubSystem, "blocks_total"),
+			"Total number of blocks to be processed in the current phase",
+			variableLabels,
+			nil,
+		),
+		blocksDone: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, progressSubSystem, "blocks_done"),
+			"Number of blocks already processed in the current phase",
+			variableLabels,
+			nil,
+		),
+		tuplesTotal: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, progressSubSystem, "tuples_total"),
+			"Total number of tuples to be processed in the current phase",
+			variableLabels,
+			nil,
+		),
+		tuplesDone: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, progressSubSystem, "tuples_done"),
+			"Number of tuples already processed in the current phase",
+			variableLabels,
+			nil,
+		),
+		elapsedSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, progressSubSystem, "elapsed_seconds"),
+			"Time since the command started",
+			variableLabels,
+			nil,
+		),
+		autoVacuum: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, progressSubSystem, "autovacuum"),
+			"Whether the command is run by an autovacuum worker rather than manually",
+			variableLabels,
+			nil,
+		),
+	}
+}
+
+// Describe implements the prometheus.Collector.
+func (c *PgStatProgressCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.phase
+	ch <- c.blocksTotal
+	ch <- c.blocksDone
+	ch <- c.tuplesTotal
+	ch <- c.tuplesDone
+	ch <- c.elapsedSeconds
+	ch <- c.autoVacuum
+}
+
+// Collect implements the promtheus.Collector.
+func (c *PgStatProgressCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.Scrape(context.Background(), ch)
+}
+
+// Scrape implements our Scraper interface.
+func (c *PgStatProgressCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
+	start := time.Now()
+	defer func() {
+		log.Infof("progress scrape took %dms", time.Now().Sub(start).Milliseconds())
+	}()
+	group := errgroup.Group{}
+	for _, dbClient := range c.dbClients {
+		dbClient := dbClient
+		group.Go(func() error { return c.scrape(ctx, dbClient, ch) })
+	}
+	if err := group.Wait(); err != nil {
+		return fmt.Errorf("scraping: %w", err)
+	}
+	return nil
+}
+
+func (c *PgStatProgressCollector) scrape(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	progressStats, err := dbClient.SelectPgStatProgress(ctx)
+	if err != nil {
+		return fmt.Errorf("progress stats: %w", err)
+	}
+	for _, stat := range progressStats {
+		labels := []string{stat.Database, stat.DatName, stat.SchemaName, stat.RelName, strconv.Itoa(stat.Pid), stat.Command}
+		known := false
+		for _, phase := range progressPhases[stat.Progress] {
+			known = known || phase == stat.Phase
+			ch <- prometheus.MustNewConstMetric(c.phase, prometheus.GaugeValue, boolValue(phase == stat.Phase), append(labels, phase)...)
+		}
+		if !known {
+			ch <- prometheus.MustNewConstMetric(c.phase, prometheus.GaugeValue, 1, append(labels, stat.Phase)...)
+		}
+		for desc, value := range map[*prometheus.Desc]*int{
+			c.blocksTotal: stat.BlocksTotal,
+			c.blocksDone:  stat.BlocksDone,
+			c.tuplesTotal: stat.TuplesTotal,
+			c.tuplesDone:  stat.TuplesDone,
+		} {
+			// Null where the view has no such column.
+			if value != nil {
+				ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, float64(*value), labels...)
+			}
+		}
+		if stat.ElapsedSeconds != nil {
+			ch <- prometheus.MustNewConstMetric(c.elapsedSeconds, prometheus.GaugeValue, *stat.ElapsedSeconds, labels...)
+		}
+		ch <- prometheus.MustNewConstMetric(c.autoVacuum, prometheus.GaugeValue, boolValue(stat.AutoVacuum), labels...)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/odonate/postgres-exporter/exporter/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+// PgStatReplicationCollector collects from pg_stat_replication on primaries,
+// and from pg_stat_wal_receiver on standbys.
+type PgStatReplicationCollector struct {
+	dbClients []*db.Client
+
+	isInRecovery *prometheus.Desc
+
+	// Primaries, per standby.
+	standbyInfo      *prometheus.Desc
+	sentLagBytes     *prometheus.Desc
+	writeLagBytes    *prometheus.Desc
+	flushLagBytes    *prometheus.Desc
+	replayLagBytes   *prometheus.Desc
+	writeLagSeconds  *prometheus.Desc
+	flushLagSeconds  *prometheus.Desc
+	replayLagSeconds *prometheus.Desc
+
+	// Standbys.
+	walReceiverInfo       *prometheus.Desc
+	receiveReplayLagBytes *prometheus.Desc
+	lastReplayTimestamp   *prometheus.Desc
+	replayDelaySeconds    *prometheus.Desc
+}
+
+// NewPgStatReplicationCollector instantiates and returns a new PgStatReplicationCollector.
+func NewPgStatReplicationCollector(dbClients []*db.Client) *PgStatReplicationCollector {
+	variableLabels := []string{"database"}
+	standbyLabels := []string{"database", "application_name", "client_addr"}
+	return &PgStatReplicationCollector{
+		dbClients: dbClients,
+
+		isInRecovery: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSubSystem, "is_in_recovery"),
+			"Whether the server is a standby, i.e. in recovery",
+			variableLabels,
+			nil,
+		),
+		standbyInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSubSystem, "standby_info"),
+			"State of a standby connected to this primary, always 1",
+			append(standbyLabels, "state", "sync_state"),
+			nil,
+		),
+		sentLagBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSubSystem, "sent_lag_bytes"),
+			"Bytes of WAL not yet sent to the standby",
+			standbyLabels,
+			nil,
+		),
+		writeLagBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSubSystem, "write_lag_bytes"),
+			"Bytes of WAL not yet written to disk by the standby",
+			standbyLabels,
+			nil,
+		),
+		flushLagBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSubSystem, "flush_lag_bytes"),
+			"Bytes of WAL not yet flushed to disk by the standby",
+			standbyLabels,
+			nil,
+		),
+		replayLagBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSubSystem, "replay_lag_bytes"),
+			"Bytes of WAL not yet replayed by the standby",
+			standbyLabels,
+			nil,
+		),
+		writeLagSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSubSystem, "write_lag_seconds"),
+			"Time elapsed between flushing recent WAL locally and receiving notification that the standby has written it",
+			standbyLabels,
+			nil,
+		),
+		flushLagSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSubSystem, "flush_lag_seconds"),
+			"Time elapsed between flushing recent WAL locally and receiving notification that the standby has flushed it",
+			standbyLabels,
+			nil,
+		),
+		replayLagSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSubSystem, "replay_lag_seconds"),
+			"Time elapsed between flushing recent WAL locally and receiving notification that the standby has replayed it",
+			standbyLabels,
+			nil,
+		),
+		walReceiverInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSubSystem, "wal_receiver_info"),
+			"State of the WAL receiver of this standby, always 1",
+			append(variableLabels, "status", "sender_host", "slot_name"),
+			nil,
+		),
+		receiveReplayLagBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSubSystem, "receive_replay_lag_bytes"),
+			"Bytes of WAL received but not yet replayed by this standby",
+			variableLabels,
+			nil,
+		),
+		lastReplayTimestamp: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSubSystem, "last_replay_timestamp_seconds"),
+			"Commit time of the last transaction replayed by this standby, in seconds since the epoch",
+			variableLabels,
+			nil,
+		),
+		replayDelaySeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, replicationSubSystem, "replay_delay_seconds"),
+			"Time since the last transaction replayed by this standby was committed, or 0 if all received WAL was replayed",
+			variableLabels,
+			nil,
+		),
+	}
+}
+
+// Describe implements the prometheus.Collector.
+func (c *PgStatReplicationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.isInRecovery
+	ch <- c.standbyInfo
+	ch <- c.sentLagBytes
+	ch <- c.writeLagBytes
+	ch <- c.flushLagBytes
+	ch <- c.replayLagBytes
+	ch <- c.writeLagSeconds
+	ch <- c.flushLagSeconds
+	ch <- c.replayLagSeconds
+	ch <- c.walReceiverInfo
+	ch <- c.receiveReplayLagBytes
+	ch <- c.lastReplayTimestamp
+	ch <- c.replayDelaySeconds
+}
+
+// Collect implements the promtheus.Collector.
+func (c *PgStatReplicationCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.Scrape(context.Background(), ch)
+}
+
+// Scrape implements our Scraper interface.
+func (c *PgStatReplicationCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
+	start := time.Now()
+	defer func() {
+		log.Infof("replication scrape took %dms", time.Now().Sub(start).Milliseconds())
+	}()
+	group := errgroup.Group{}
+	for _, dbClient := range c.dbClients {
+		dbClient := dbClient
+		group.Go(func() error { return c.scrape(ctx, dbClient, ch) })
+	}
+	if err := group.Wait(); err != nil {
+		return fmt.Errorf("scraping: %w", err)
+	}
+	return nil
+}
+
+func (c *PgStatReplicationCollector) scrape(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	recoveries, err := dbClient.SelectPgRecovery(ctx)
+	if err != nil {
+		return fmt.Errorf("recovery stats: %w", err)
+	}
+	for _, recovery := range recoveries {
+		if !recovery.IsInRecovery {
+			ch <- prometheus.MustNewConstMetric(c.isInRecovery, prometheus.GaugeValue, 0, recovery.Database)
+			return c.scrapePrimary(ctx, dbClient, ch)
+		}
+		ch <- prometheus.MustNewConstMetric(c.isInRecovery, prometheus.GaugeValue, 1, recovery.Database)
+		if recovery.ReceiveReplayLagBytes != nil {
+			ch <- prometheus.MustNewConstMetric(c.receiveReplayLagBytes, prometheus.GaugeValue, *recovery.ReceiveReplayLagBytes, recovery.Database)
+		}
+		if recovery.LastReplayTimestamp != nil {
+			ch <- prometheus.MustNewConstMetric(c.lastReplayTimestamp, prometheus.GaugeValue, *recovery.LastReplayTimestamp, recovery.Database)
+		}
+		if recovery.ReplayDelaySeconds != nil {
+			ch <- prometheus.MustNewConstMetric(c.replayDelaySeconds, prometheus.GaugeValue, *recovery.ReplayDelaySeconds, recovery.Database)
+		}
+		return c.scrapeStandby(ctx, dbClient, ch)
+	}
+	return nil
+}
+
+// scrapePrimary scrapes the standbys connected to a primary.
+func (c *PgStatReplicationCollector) scrapePrimary(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	replicationStats, err := dbClient.SelectPgStatReplication(ctx)
+	if err != nil {
+		return fmt.Errorf("replication stats: %w", err)
+	}
+	for _, stat := range replicationStats {
+		labels := []string{stat.Database, stat.ApplicationName, stat.ClientAddr}
+		ch <- prometheus.MustNewConstMetric(c.standbyInfo, prometheus.GaugeValue, 1, append(labels, stat.State, stat.SyncState)...)
+		for desc, value := range map[*prometheus.Desc]*float64{
+			c.sentLagBytes:     stat.SentLagBytes,
+			c.writeLagBytes:    stat.WriteLagBytes,
+			c.flushLagBytes:    stat.FlushLagBytes,
+			c.replayLagBytes:   stat.ReplayLagBytes,
+			c.writeLagSeconds:  stat.WriteLagSeconds,
+			c.flushLagSeconds:  stat.FlushLagSeconds,
+			c.replayLagSeconds: stat.ReplayLagSeconds,
+		} {
+			// Null without the pg_read_all_stats role, or until the standby reports its progress.
+			if value != nil {
+				ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, *value, labels...)
+			}
+		}
+	}
+	return nil
+}
+
+// scrapeStandby scrapes the WAL receiver of a standby, of which there is none while restoring from archives.
+func (c *PgStatReplicationCollector) scrapeStandby(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	walReceiverStats, err := dbClient.SelectPgStatWalReceiver(ctx)
+	if err != nil {
+		return fmt.Errorf("wal receiver stats: %w", err)
+	}
+	for _, stat := range walReceiverStats {
+		ch <- prometheus.MustNewConstMetric(c.walReceiverInfo, prometheus.GaugeValue, 1, stat.Database, stat.Status, stat.SenderHost, stat.SlotName)
+	}
+	return nil
+}
@@ -186,11 +186,11 @@ func (c *PgStatStatementsCollector) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect implements the promtheus.Collector.
 func (c *PgStatStatementsCollector) Collect(ch chan<- prometheus.Metric) {
-	_ = c.Scrape(ch)
+	_ = c.Scrape(context.Background(), ch)
 }
 
 // Scrape implements our Scraper interfacc.
-func (c *PgStatStatementsCollector) Scrape(ch chan<- prometheus.Metric) error {
+func (c *PgStatStatementsCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
 	start := time.Now()
 	defer func() {
 		log.Infof("statement scrape took %dms", time.Now().Sub(start).Milliseconds())
@@ -198,7 +198,7 @@ func (c *PgStatStatementsCollector) Scrape(ch chan<- prometheus.Metric) error {
 	group := errgroup.Group{}
 	for _, dbClient := range c.dbClients {
 		dbClient := dbClient
-		group.Go(func() error { return c.scrape(dbClient, ch) })
+		group.Go(func() error { return c.scrape(ctx, dbClient, ch) })
 	}
 	if err := group.Wait(); err != nil {
 		return fmt.Errorf("scraping: %w", err)
@@ -206,11 +206,21 @@ func (c *PgStatStatementsCollector) Scrape(ch chan<- prometheus.Metric) error {
 	return nil
 }
 
-func (c *PgStatStatementsCollector) scrape(dbClient *db.Client, ch chan<- prometheus.Metric) error {
-	statementStats, err := dbClient.SelectPgStatStatements(context.Background())
+func (c *PgStatStatementsCollector) scrape(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	// The extension may be created after connecting, so is looked up on every scrape until it is.
+	installed, err := dbClient.LookupExtension(ctx, "pg_stat_statements")
 	if err != nil {
 		return fmt.Errorf("statement stats: %w", err)
 	}
+	if !installed {
+		return nil
+	}
+	statementStats, err := dbClient.SelectPgStatStatements(ctx)
+	if err != nil {
+		// The extension may have been dropped, in which case the next scrapes skip it.
+		_ = dbClient.DetectExtensions(ctx)
+		return fmt.Errorf("statement stats: %w", err)
+	}
 	start := time.Now()
 	log.Infof("statements lock aquire %dms", time.Now().Sub(start).Milliseconds())
 	for _, stat := range statementStats {
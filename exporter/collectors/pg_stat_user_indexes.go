@@ -56,11 +56,11 @@ func (c *PgStatUserIndexesCollector) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect implements the promtheus.Collector.
 func (c *PgStatUserIndexesCollector) Collect(ch chan<- prometheus.Metric) {
-	_ = c.Scrape(ch)
+	_ = c.Scrape(context.Background(), ch)
 }
 
 // Scrape implements our Scraper interface.
-func (c *PgStatUserIndexesCollector) Scrape(ch chan<- prometheus.Metric) error {
+func (c *PgStatUserIndexesCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
 	start := time.Now()
 	defer func() {
 		log.Infof("user indexes scrape took %dms", time.Now().Sub(start).Milliseconds())
@@ -68,7 +68,7 @@ func (c *PgStatUserIndexesCollector) Scrape(ch chan<- prometheus.Metric) error {
 	group := errgroup.Group{}
 	for _, dbClient := range c.dbClients {
 		dbClient := dbClient
-		group.Go(func() error { return c.scrape(dbClient, ch) })
+		group.Go(func() error { return c.scrape(ctx, dbClient, ch) })
 	}
 	if err := group.Wait(); err != nil {
 		return fmt.Errorf("scraping: %w", err)
@@ -76,8 +76,8 @@ func (c *PgStatUserIndexesCollector) Scrape(ch chan<- prometheus.Metric) error {
 	return nil
 }
 
-func (c *PgStatUserIndexesCollector) scrape(dbClient *db.Client, ch chan<- prometheus.Metric) error {
-	userIndexStats, err := dbClient.SelectPgStatUserIndexes(context.Background())
+func (c *PgStatUserIndexesCollector) scrape(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	userIndexStats, err := dbClient.SelectPgStatUserIndexes(ctx)
 	if err != nil {
 		return fmt.Errorf("user indexes stats: %w", err)
 	}
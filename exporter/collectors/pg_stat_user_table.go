@@ -27,6 +27,7 @@ type PgStatUserTableCollector struct {
 	nLiveTup         *prometheus.Desc
 	nDeadTup         *prometheus.Desc
 	nModSinceAnalyze *prometheus.Desc
+	nInsSinceVacuum  *prometheus.Desc
 	lastVacuum       *prometheus.Desc
 	lastAutoVacuum   *prometheus.Desc
 	lastAnalyze      *prometheus.Desc
@@ -108,6 +109,12 @@ func NewPgStatUserTableCollector(dbClients []*db.Client) *PgStatUserTableCollect
 			variableLabels,
 			nil,
 		),
+		nInsSinceVacuum: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, userTablesSubSystem, "n_ins_since_vacuum"),
+			"Estimated number of rows inserted since last vacuum (PG13+)",
+			variableLabels,
+			nil,
+		),
 		lastVacuum: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, userTablesSubSystem, "last_vacuum"),
 			"Last time at which this table was manually vacuumed (not counting VACUUM FULL)",
@@ -172,6 +179,7 @@ func (c *PgStatUserTableCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.nLiveTup
 	ch <- c.nDeadTup
 	ch <- c.nModSinceAnalyze
+	ch <- c.nInsSinceVacuum
 	ch <- c.lastVacuum
 	ch <- c.lastAutoVacuum
 	ch <- c.lastAnalyze
@@ -186,11 +194,11 @@ func (c *PgStatUserTableCollector) Describe(ch chan<- *prometheus.Desc) {
 func (c *PgStatUserTableCollector) Collect(ch chan<- prometheus.Metric) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
-	_ = c.Scrape(ch)
+	_ = c.Scrape(context.Background(), ch)
 }
 
 // Scrape implements our Scraper interface.
-func (c *PgStatUserTableCollector) Scrape(ch chan<- prometheus.Metric) error {
+func (c *PgStatUserTableCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
 	start := time.Now()
 	defer func() {
 		log.Infof("user table scrape took %dms", time.Now().Sub(start).Milliseconds())
@@ -198,7 +206,7 @@ func (c *PgStatUserTableCollector) Scrape(ch chan<- prometheus.Metric) error {
 	group := errgroup.Group{}
 	for _, dbClient := range c.dbClients {
 		dbClient := dbClient
-		group.Go(func() error { return c.scrape(dbClient, ch) })
+		group.Go(func() error { return c.scrape(ctx, dbClient, ch) })
 	}
 	if err := group.Wait(); err != nil {
 		return fmt.Errorf("scraping: %w", err)
@@ -206,8 +214,8 @@ func (c *PgStatUserTableCollector) Scrape(ch chan<- prometheus.Metric) error {
 	return nil
 }
 
-func (c *PgStatUserTableCollector) scrape(dbClient *db.Client, ch chan<- prometheus.Metric) error {
-	userTableStats, err := dbClient.SelectPgStatUserTables(context.Background())
+func (c *PgStatUserTableCollector) scrape(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	userTableStats, err := dbClient.SelectPgStatUserTables(ctx)
 	if err != nil {
 		return fmt.Errorf("user table stats: %w", err)
 	}
@@ -225,6 +233,9 @@ func (c *PgStatUserTableCollector) scrape(dbClient *db.Client, ch chan<- prometh
 		ch <- prometheus.MustNewConstMetric(c.nLiveTup, prometheus.GaugeValue, float64(stat.NLiveTup), stat.Database, stat.SchemaName, stat.RelName)
 		ch <- prometheus.MustNewConstMetric(c.nDeadTup, prometheus.GaugeValue, float64(stat.NDeadTup), stat.Database, stat.SchemaName, stat.RelName)
 		ch <- prometheus.MustNewConstMetric(c.nModSinceAnalyze, prometheus.GaugeValue, float64(stat.NModSinceAnalyze), stat.Database, stat.SchemaName, stat.RelName)
+		if stat.NInsSinceVacuum != nil {
+			ch <- prometheus.MustNewConstMetric(c.nInsSinceVacuum, prometheus.GaugeValue, float64(*stat.NInsSinceVacuum), stat.Database, stat.SchemaName, stat.RelName)
+		}
 		ch <- prometheus.MustNewConstMetric(c.lastVacuum, prometheus.GaugeValue, float64(stat.LastVacuum.Time.UnixMicro()), stat.Database, stat.SchemaName, stat.RelName)
 		ch <- prometheus.MustNewConstMetric(c.lastAutoVacuum, prometheus.GaugeValue, float64(stat.LastAutoVacuum.Time.UnixMicro()), stat.Database, stat.SchemaName, stat.RelName)
 		ch <- prometheus.MustNewConstMetric(c.lastAnalyze, prometheus.GaugeValue, float64(stat.LastAnalyze.Time.UnixMicro()), stat.Database, stat.SchemaName, stat.RelName)
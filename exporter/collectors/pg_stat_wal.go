@@ -0,0 +1,210 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/odonate/postgres-exporter/exporter/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+// PgStatWalCollector collects from pg_stat_archiver, and pg_stat_wal from PG14.
+type PgStatWalCollector struct {
+	dbClients []*db.Client
+
+	archivedCount         *prometheus.Desc
+	failedCount           *prometheus.Desc
+	lastArchivedTimestamp *prometheus.Desc
+	lastFailedTimestamp   *prometheus.Desc
+	archiverInfo          *prometheus.Desc
+
+	lsnBytes         *prometheus.Desc
+	records          *prometheus.Desc
+	fpi              *prometheus.Desc
+	bytes            *prometheus.Desc
+	buffersFull      *prometheus.Desc
+	write            *prometheus.Desc
+	sync             *prometheus.Desc
+	writeTimeSeconds *prometheus.Desc
+	syncTimeSeconds  *prometheus.Desc
+}
+
+// NewPgStatWalCollector instantiates and returns a new PgStatWalCollector.
+func NewPgStatWalCollector(dbClients []*db.Client) *PgStatWalCollector {
+	variableLabels := []string{"database"}
+	return &PgStatWalCollector{
+		dbClients: dbClients,
+
+		archivedCount: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, archiverSubSystem, "archived_count"),
+			"Number of WAL files that have been successfully archived",
+			variableLabels,
+			nil,
+		),
+		failedCount: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, archiverSubSystem, "failed_count"),
+			"Number of failed attempts for archiving WAL files",
+			variableLabels,
+			nil,
+		),
+		lastArchivedTimestamp: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, archiverSubSystem, "last_archived_timestamp_seconds"),
+			"Time of the last successful archive operation, in seconds since the epoch",
+			variableLabels,
+			nil,
+		),
+		lastFailedTimestamp: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, archiverSubSystem, "last_failed_timestamp_seconds"),
+			"Time of the last failed archival operation, in seconds since the epoch",
+			variableLabels,
+			nil,
+		),
+		archiverInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, archiverSubSystem, "info"),
+			"Names of the last WAL files successfully archived and failed to be archived, always 1",
+			append(variableLabels, "last_archived_wal", "last_failed_wal"),
+			nil,
+		),
+		lsnBytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, walSubSystem, "lsn_bytes"),
+			"Current WAL LSN as a byte position, or the last replayed one on standbys",
+			variableLabels,
+			nil,
+		),
+		records: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, walSubSystem, "records"),
+			"Total number of WAL records generated (PG14+)",
+			variableLabels,
+			nil,
+		),
+		fpi: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, walSubSystem, "fpi"),
+			"Total number of WAL full page images generated (PG14+)",
+			variableLabels,
+			nil,
+		),
+		bytes: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, walSubSystem, "bytes"),
+			"Total amount of WAL generated in bytes (PG14+)",
+			variableLabels,
+			nil,
+		),
+		buffersFull: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, walSubSystem, "buffers_full"),
+			"Number of times WAL data was written to disk because WAL buffers became full (PG14+)",
+			variableLabels,
+			nil,
+		),
+		write: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, walSubSystem, "write"),
+			"Number of times WAL buffers were written out to disk (PG14+)",
+			variableLabels,
+			nil,
+		),
+		sync: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, walSubSystem, "sync"),
+			"Number of times WAL files were synced to disk (PG14+)",
+			variableLabels,
+			nil,
+		),
+		writeTimeSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, walSubSystem, "write_time_seconds"),
+			"Time spent writing WAL buffers to disk, in seconds (PG14+, with track_wal_io_timing)",
+			variableLabels,
+			nil,
+		),
+		syncTimeSeconds: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, walSubSystem, "sync_time_seconds"),
+			"Time spent syncing WAL files to disk, in seconds (PG14+, with track_wal_io_timing)",
+			variableLabels,
+			nil,
+		),
+	}
+}
+
+// Describe implements the prometheus.Collector.
+func (c *PgStatWalCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.archivedCount
+	ch <- c.failedCount
+	ch <- c.lastArchivedTimestamp
+	ch <- c.lastFailedTimestamp
+	ch <- c.archiverInfo
+	ch <- c.lsnBytes
+	ch <- c.records
+	ch <- c.fpi
+	ch <- c.bytes
+	ch <- c.buffersFull
+	ch <- c.write
+	ch <- c.sync
+	ch <- c.writeTimeSeconds
+	ch <- c.syncTimeSeconds
+}
+
+// Collect implements the promtheus.Collector.
+func (c *PgStatWalCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.Scrape(context.Background(), ch)
+}
+
+// Scrape implements our Scraper interface.
+func (c *PgStatWalCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
+	start := time.Now()
+	defer func() {
+		log.Infof("wal scrape took %dms", time.Now().Sub(start).Milliseconds())
+	}()
+	group := errgroup.Group{}
+	for _, dbClient := range c.dbClients {
+		dbClient := dbClient
+		group.Go(func() error { return c.scrapeArchiver(ctx, dbClient, ch) })
+		group.Go(func() error { return c.scrapeWal(ctx, dbClient, ch) })
+	}
+	if err := group.Wait(); err != nil {
+		return fmt.Errorf("scraping: %w", err)
+	}
+	return nil
+}
+
+func (c *PgStatWalCollector) scrapeArchiver(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	archiverStats, err := dbClient.SelectPgStatArchiver(ctx)
+	if err != nil {
+		return fmt.Errorf("archiver stats: %w", err)
+	}
+	for _, stat := range archiverStats {
+		ch <- prometheus.MustNewConstMetric(c.archivedCount, prometheus.CounterValue, float64(stat.ArchivedCount), stat.Database)
+		ch <- prometheus.MustNewConstMetric(c.failedCount, prometheus.CounterValue, float64(stat.FailedCount), stat.Database)
+		if stat.LastArchivedTime != nil {
+			ch <- prometheus.MustNewConstMetric(c.lastArchivedTimestamp, prometheus.GaugeValue, *stat.LastArchivedTime, stat.Database)
+		}
+		if stat.LastFailedTime != nil {
+			ch <- prometheus.MustNewConstMetric(c.lastFailedTimestamp, prometheus.GaugeValue, *stat.LastFailedTime, stat.Database)
+		}
+		ch <- prometheus.MustNewConstMetric(c.archiverInfo, prometheus.GaugeValue, 1, stat.Database, stat.LastArchivedWal, stat.LastFailedWal)
+	}
+	return nil
+}
+
+func (c *PgStatWalCollector) scrapeWal(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	walStats, err := dbClient.SelectPgStatWal(ctx)
+	if err != nil {
+		return fmt.Errorf("wal stats: %w", err)
+	}
+	for _, stat := range walStats {
+		if stat.WalLSNBytes != nil {
+			ch <- prometheus.MustNewConstMetric(c.lsnBytes, prometheus.CounterValue, *stat.WalLSNBytes, stat.Database)
+		}
+		if stat.WalRecords == nil {
+			// Before PG14.
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(c.records, prometheus.CounterValue, float64(*stat.WalRecords), stat.Database)
+		ch <- prometheus.MustNewConstMetric(c.fpi, prometheus.CounterValue, float64(*stat.WalFpi), stat.Database)
+		ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.CounterValue, *stat.WalBytes, stat.Database)
+		ch <- prometheus.MustNewConstMetric(c.buffersFull, prometheus.CounterValue, float64(*stat.WalBuffersFull), stat.Database)
+		ch <- prometheus.MustNewConstMetric(c.write, prometheus.CounterValue, float64(*stat.WalWrite), stat.Database)
+		ch <- prometheus.MustNewConstMetric(c.sync, prometheus.CounterValue, float64(*stat.WalSync), stat.Database)
+		ch <- prometheus.MustNewConstMetric(c.writeTimeSeconds, prometheus.CounterValue, *stat.WalWriteTimeSeconds, stat.Database)
+		ch <- prometheus.MustNewConstMetric(c.syncTimeSeconds, prometheus.CounterValue, *stat.WalSyncTimeSeconds, stat.Database)
+	}
+	return nil
+}
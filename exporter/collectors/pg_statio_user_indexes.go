@@ -49,11 +49,11 @@ func (c *PgStatIOUserIndexesCollector) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect implements the promtheus.Collector.
 func (c *PgStatIOUserIndexesCollector) Collect(ch chan<- prometheus.Metric) {
-	_ = c.Scrape(ch)
+	_ = c.Scrape(context.Background(), ch)
 }
 
 // Scrape implements our Scraper interface.
-func (c *PgStatIOUserIndexesCollector) Scrape(ch chan<- prometheus.Metric) error {
+func (c *PgStatIOUserIndexesCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
 	start := time.Now()
 	defer func() {
 		log.Infof("I/O user index scrape took %dms", time.Now().Sub(start).Milliseconds())
@@ -61,7 +61,7 @@ func (c *PgStatIOUserIndexesCollector) Scrape(ch chan<- prometheus.Metric) error
 	group := errgroup.Group{}
 	for _, dbClient := range c.dbClients {
 		dbClient := dbClient
-		group.Go(func() error { return c.scrape(dbClient, ch) })
+		group.Go(func() error { return c.scrape(ctx, dbClient, ch) })
 	}
 	if err := group.Wait(); err != nil {
 		return fmt.Errorf("scraping: %w", err)
@@ -69,8 +69,8 @@ func (c *PgStatIOUserIndexesCollector) Scrape(ch chan<- prometheus.Metric) error
 	return nil
 }
 
-func (c *PgStatIOUserIndexesCollector) scrape(dbClient *db.Client, ch chan<- prometheus.Metric) error {
-	userIndexesStats, err := dbClient.SelectPgStatIOUserIndexes(context.Background())
+func (c *PgStatIOUserIndexesCollector) scrape(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	userIndexesStats, err := dbClient.SelectPgStatIOUserIndexes(ctx)
 	if err != nil {
 		return fmt.Errorf("user table stats: %w", err)
 	}
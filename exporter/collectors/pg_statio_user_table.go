@@ -98,11 +98,11 @@ func (c *PgStatIOUserTableCollector) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect implements the promtheus.Collector.
 func (c *PgStatIOUserTableCollector) Collect(ch chan<- prometheus.Metric) {
-	_ = c.Scrape(ch)
+	_ = c.Scrape(context.Background(), ch)
 }
 
 // Scrape implements our Scraper interface.
-func (c *PgStatIOUserTableCollector) Scrape(ch chan<- prometheus.Metric) error {
+func (c *PgStatIOUserTableCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
 	start := time.Now()
 	defer func() {
 		log.Infof("I/O user table scrape took %dms", time.Now().Sub(start).Milliseconds())
@@ -110,7 +110,7 @@ func (c *PgStatIOUserTableCollector) Scrape(ch chan<- prometheus.Metric) error {
 	group := errgroup.Group{}
 	for _, dbClient := range c.dbClients {
 		dbClient := dbClient
-		group.Go(func() error { return c.scrape(dbClient, ch) })
+		group.Go(func() error { return c.scrape(ctx, dbClient, ch) })
 	}
 	if err := group.Wait(); err != nil {
 		return fmt.Errorf("scraping: %w", err)
@@ -118,8 +118,8 @@ func (c *PgStatIOUserTableCollector) Scrape(ch chan<- prometheus.Metric) error {
 	return nil
 }
 
-func (c *PgStatIOUserTableCollector) scrape(dbClient *db.Client, ch chan<- prometheus.Metric) error {
-	userTableStats, err := dbClient.SelectPgStatIOUserTables(context.Background())
+func (c *PgStatIOUserTableCollector) scrape(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	userTableStats, err := dbClient.SelectPgStatIOUserTables(ctx)
 	if err != nil {
 		return fmt.Errorf("user table stats: %w", err)
 	}
@@ -0,0 +1,152 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/odonate/postgres-exporter/exporter/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/errgroup"
+)
+
+// PgWraparoundCollector collects the age of the oldest unfrozen transaction and multixact IDs
+// of databases and relations, along with what holds back their freezing.
+type PgWraparoundCollector struct {
+	dbClients []*db.Client
+	// relations is the number of relations with the oldest unfrozen transaction IDs to export.
+	relations int
+
+	xidAge                          *prometheus.Desc
+	mxidAge                         *prometheus.Desc
+	autoVacuumFreezeMaxAge          *prometheus.Desc
+	autoVacuumMultixactFreezeMaxAge *prometheus.Desc
+	relationXidAge                  *prometheus.Desc
+	relationMxidAge                 *prometheus.Desc
+	oldestXminAge                   *prometheus.Desc
+}
+
+// NewPgWraparoundCollector instantiates and returns a new PgWraparoundCollector.
+func NewPgWraparoundCollector(dbClients []*db.Client, opts Opts) *PgWraparoundCollector {
+	variableLabels := []string{"database", "datname"}
+	relationLabels := []string{"database", "schemaname", "relname"}
+	return &PgWraparoundCollector{
+		dbClients: dbClients,
+		relations: opts.WraparoundRelations,
+
+		xidAge: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, wraparoundSubSystem, "xid_age"),
+			"Age of the oldest unfrozen transaction ID of the database, i.e. age(datfrozenxid)",
+			variableLabels,
+			nil,
+		),
+		mxidAge: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, wraparoundSubSystem, "mxid_age"),
+			"Age of the oldest unfrozen multixact ID of the database, i.e. mxid_age(datminmxid)",
+			variableLabels,
+			nil,
+		),
+		autoVacuumFreezeMaxAge: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, wraparoundSubSystem, "autovacuum_freeze_max_age"),
+			"Transaction ID age at which autovacuum is forced to prevent wraparound",
+			variableLabels,
+			nil,
+		),
+		autoVacuumMultixactFreezeMaxAge: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, wraparoundSubSystem, "autovacuum_multixact_freeze_max_age"),
+			"Multixact ID age at which autovacuum is forced to prevent wraparound",
+			variableLabels,
+			nil,
+		),
+		relationXidAge: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, wraparoundSubSystem, "relation_xid_age"),
+			"Age of the oldest unfrozen transaction ID of the relation, for the relations of the target's database with the oldest ones",
+			relationLabels,
+			nil,
+		),
+		relationMxidAge: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, wraparoundSubSystem, "relation_mxid_age"),
+			"Age of the oldest unfrozen multixact ID of the relation, for the relations of the target's database with the oldest transaction IDs",
+			relationLabels,
+			nil,
+		),
+		oldestXminAge: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, wraparoundSubSystem, "oldest_xmin_age"),
+			"Age of the oldest xmin held by a backend, prepared transaction, replication slot or standby, which blocks freezing",
+			[]string{"database", "holder", "id"},
+			nil,
+		),
+	}
+}
+
+// Describe implements the prometheus.Collector.
+func (c *PgWraparoundCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.xidAge
+	ch <- c.mxidAge
+	ch <- c.autoVacuumFreezeMaxAge
+	ch <- c.autoVacuumMultixactFreezeMaxAge
+	ch <- c.relationXidAge
+	ch <- c.relationMxidAge
+	ch <- c.oldestXminAge
+}
+
+// Collect implements the promtheus.Collector.
+func (c *PgWraparoundCollector) Collect(ch chan<- prometheus.Metric) {
+	_ = c.Scrape(context.Background(), ch)
+}
+
+// Scrape implements our Scraper interface.
+func (c *PgWraparoundCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
+	start := time.Now()
+	defer func() {
+		log.Infof("wraparound scrape took %dms", time.Now().Sub(start).Milliseconds())
+	}()
+	group := errgroup.Group{}
+	for _, dbClient := range c.dbClients {
+		dbClient := dbClient
+		group.Go(func() error { return c.scrapeDatabases(ctx, dbClient, ch) })
+		group.Go(func() error { return c.scrapeRelations(ctx, dbClient, ch) })
+		group.Go(func() error { return c.scrapeXminHolders(ctx, dbClient, ch) })
+	}
+	if err := group.Wait(); err != nil {
+		return fmt.Errorf("scraping: %w", err)
+	}
+	return nil
+}
+
+func (c *PgWraparoundCollector) scrapeDatabases(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	databaseStats, err := dbClient.SelectPgDatabaseWraparound(ctx)
+	if err != nil {
+		return fmt.Errorf("database wraparound stats: %w", err)
+	}
+	for _, stat := range databaseStats {
+		ch <- prometheus.MustNewConstMetric(c.xidAge, prometheus.GaugeValue, float64(stat.XidAge), stat.Database, stat.DatName)
+		ch <- prometheus.MustNewConstMetric(c.mxidAge, prometheus.GaugeValue, float64(stat.MxidAge), stat.Database, stat.DatName)
+		ch <- prometheus.MustNewConstMetric(c.autoVacuumFreezeMaxAge, prometheus.GaugeValue, float64(stat.AutoVacuumFreezeMaxAge), stat.Database, stat.DatName)
+		ch <- prometheus.MustNewConstMetric(c.autoVacuumMultixactFreezeMaxAge, prometheus.GaugeValue, float64(stat.AutoVacuumMultixactFreezeMaxAge), stat.Database, stat.DatName)
+	}
+	return nil
+}
+
+func (c *PgWraparoundCollector) scrapeRelations(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	relationStats, err := dbClient.SelectPgRelationWraparound(ctx, c.relations)
+	if err != nil {
+		return fmt.Errorf("relation wraparound stats: %w", err)
+	}
+	for _, stat := range relationStats {
+		ch <- prometheus.MustNewConstMetric(c.relationXidAge, prometheus.GaugeValue, float64(stat.XidAge), stat.Database, stat.SchemaName, stat.RelName)
+		ch <- prometheus.MustNewConstMetric(c.relationMxidAge, prometheus.GaugeValue, float64(stat.MxidAge), stat.Database, stat.SchemaName, stat.RelName)
+	}
+	return nil
+}
+
+func (c *PgWraparoundCollector) scrapeXminHolders(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	xminHolders, err := dbClient.SelectPgXminHolders(ctx)
+	if err != nil {
+		return fmt.Errorf("xmin holders: %w", err)
+	}
+	for _, holder := range xminHolders {
+		ch <- prometheus.MustNewConstMetric(c.oldestXminAge, prometheus.GaugeValue, float64(holder.XminAge), holder.Database, holder.Holder, holder.ID)
+	}
+	return nil
+}
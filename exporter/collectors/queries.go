@@ -0,0 +1,436 @@
+package collectors
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/odonate/postgres-exporter/exporter/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/yaml.v3"
+)
+
+// ColumnUsage specifies how a column of a query is exported.
+type ColumnUsage string
+
+const (
+	// UsageLabel exports the column as a label of every metric of the row.
+	UsageLabel ColumnUsage = "LABEL"
+	// UsageCounter exports the column as a counter.
+	UsageCounter ColumnUsage = "COUNTER"
+	// UsageGauge exports the column as a gauge.
+	UsageGauge ColumnUsage = "GAUGE"
+	// UsageHistogram exports the column, an array of bucket upper bounds, as a histogram.
+	// The query must also select <column>_bucket, an array of cumulative counts per bucket,
+	// as well as <column>_sum and <column>_count.
+	UsageHistogram ColumnUsage = "HISTOGRAM"
+	// UsageDiscard ignores the column, as are columns missing from the query's columns.
+	UsageDiscard ColumnUsage = "DISCARD"
+)
+
+// Queries is the layout of a queries file, e.g.
+//
+//	queries:
+//	  - name: pg_replication
+//	    help: Replication lag of standbys
+//	    query: SELECT application_name, EXTRACT(EPOCH FROM replay_lag) AS lag FROM pg_stat_replication
+//	    min_server_version: 100000
+//	    cache_ttl: 30s
+//	    columns:
+//	      - name: application_name
+//	        usage: LABEL
+//	      - name: lag
+//	        usage: GAUGE
+//	        help: Replay lag in seconds
+type Queries struct {
+	Queries []Query `yaml:"queries"`
+}
+
+// Query is a user-defined SQL query whose result rows are exported as metrics named
+// <name>_<column>.
+type Query struct {
+	Name    string   `yaml:"name"`
+	Help    string   `yaml:"help"`
+	SQL     string   `yaml:"query"`
+	Columns []Column `yaml:"columns"`
+	// The query is only run on servers where MinServerVersion <= server_version_num < MaxServerVersion.
+	// Zero values are unbounded.
+	MinServerVersion int `yaml:"min_server_version"`
+	MaxServerVersion int `yaml:"max_server_version"`
+	// Databases restricts the query to targets with one of these names or databases.
+	Databases []string `yaml:"databases"`
+	// CacheTTL is how long results are reused for before the query is run again.
+	CacheTTL time.Duration `yaml:"cache_ttl"`
+}
+
+// Column specifies how a column selected by a query is exported.
+type Column struct {
+	Name  string      `yaml:"name"`
+	Usage ColumnUsage `yaml:"usage"`
+	// Help defaults to the help of the query.
+	Help string `yaml:"help"`
+}
+
+// LoadQueries reads and validates the queries file at path.
+func LoadQueries(path string) ([]Query, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading queries: %w", err)
+	}
+	var queries Queries
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&queries); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	for i, query := range queries.Queries {
+		if err := query.validate(); err != nil {
+			return nil, fmt.Errorf("validating %s: queries[%d]: %w", path, i, err)
+		}
+	}
+	return queries.Queries, nil
+}
+
+// QueriesFactory returns a factory of collectors running the queries of the file at path.
+func QueriesFactory(path string) (Factory, error) {
+	queries, err := LoadQueries(path)
+	if err != nil {
+		return nil, err
+	}
+	return func(dbClients []*db.Client) Collector { return NewQueriesCollector(dbClients, queries) }, nil
+}
+
+func (q Query) validate() error {
+	if !model.IsValidMetricName(model.LabelValue(q.Name)) {
+		return fmt.Errorf("name: invalid metric name %q", q.Name)
+	}
+	if q.SQL == "" {
+		return fmt.Errorf("query: is required")
+	}
+	metrics := 0
+	for i, column := range q.Columns {
+		switch column.Usage {
+		case UsageLabel:
+			if !model.LabelName(column.Name).IsValid() {
+				return fmt.Errorf("columns[%d].name: invalid label name %q", i, column.Name)
+			}
+		case UsageCounter, UsageGauge, UsageHistogram:
+			if !model.IsValidMetricName(model.LabelValue(q.Name + "_" + column.Name)) {
+				return fmt.Errorf("columns[%d].name: invalid metric name %q", i, q.Name+"_"+column.Name)
+			}
+			metrics++
+		case UsageDiscard:
+		default:
+			return fmt.Errorf("columns[%d].usage: unknown usage %q", i, column.Usage)
+		}
+	}
+	if metrics == 0 {
+		return fmt.Errorf("columns: at least one COUNTER, GAUGE or HISTOGRAM column is required")
+	}
+	if q.MaxServerVersion != 0 && q.MaxServerVersion <= q.MinServerVersion {
+		return fmt.Errorf("max_server_version: must exceed min_server_version")
+	}
+	return nil
+}
+
+// QueriesCollector collects the results of user-defined queries.
+type QueriesCollector struct {
+	dbClients []*db.Client
+	queries   []*query
+	mutex     sync.RWMutex
+
+	// Results of cached queries, by client.
+	cache      map[*db.Client][]cachedResult
+	cacheMutex sync.RWMutex
+}
+
+// query is a Query along with the descriptions of its metrics.
+type query struct {
+	Query
+	labels []string
+	descs  map[string]*prometheus.Desc
+}
+
+type cachedResult struct {
+	metrics   []prometheus.Metric
+	expiresAt time.Time
+}
+
+// NewQueriesCollector instantiates and returns a new QueriesCollector.
+func NewQueriesCollector(dbClients []*db.Client, queries []Query) *QueriesCollector {
+	c := &QueriesCollector{
+		dbClients: dbClients,
+		queries:   make([]*query, 0, len(queries)),
+		cache:     make(map[*db.Client][]cachedResult, len(dbClients)),
+	}
+	for _, q := range queries {
+		compiled := &query{Query: q, descs: make(map[string]*prometheus.Desc)}
+		for _, column := range q.Columns {
+			if column.Usage == UsageLabel {
+				compiled.labels = append(compiled.labels, column.Name)
+			}
+		}
+		for _, column := range q.Columns {
+			switch column.Usage {
+			case UsageCounter, UsageGauge, UsageHistogram:
+				help := column.Help
+				if help == "" {
+					help = q.Help
+				}
+				compiled.descs[column.Name] = prometheus.NewDesc(q.Name+"_"+column.Name, help, compiled.labels, nil)
+			}
+		}
+		c.queries = append(c.queries, compiled)
+	}
+	for _, dbClient := range dbClients {
+		c.cache[dbClient] = make([]cachedResult, len(c.queries))
+	}
+	return c
+}
+
+// Describe implements the prometheus.Collector.
+func (c *QueriesCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, q := range c.queries {
+		for _, desc := range q.descs {
+			ch <- desc
+		}
+	}
+}
+
+// Collect implements the promtheus.Collector.
+func (c *QueriesCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	_ = c.Scrape(context.Background(), ch)
+}
+
+// Scrape implements our Scraper interface.
+func (c *QueriesCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
+	start := time.Now()
+	defer func() {
+		log.Infof("queries scrape took %dms", time.Now().Sub(start).Milliseconds())
+	}()
+	group := errgroup.Group{}
+	for _, dbClient := range c.dbClients {
+		dbClient := dbClient
+		group.Go(func() error { return c.scrape(ctx, dbClient, ch) })
+	}
+	if err := group.Wait(); err != nil {
+		return fmt.Errorf("scraping: %w", err)
+	}
+	return nil
+}
+
+// scrape runs every query applicable to the client, carrying on past failed queries.
+func (c *QueriesCollector) scrape(ctx context.Context, dbClient *db.Client, ch chan<- prometheus.Metric) error {
+	var firstErr error
+	for i, q := range c.queries {
+		if !q.appliesTo(dbClient) {
+			continue
+		}
+		metrics, err := c.run(ctx, dbClient, i)
+		if err != nil {
+			log.Errorf("%s query %s: %s", dbClient.Name(), q.Name, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("query %s: %w", q.Name, err)
+			}
+			continue
+		}
+		for _, metric := range metrics {
+			ch <- metric
+		}
+	}
+	return firstErr
+}
+
+// run returns the metrics of the ith query, from the cache if they have not expired.
+func (c *QueriesCollector) run(ctx context.Context, dbClient *db.Client, i int) ([]prometheus.Metric, error) {
+	c.cacheMutex.RLock()
+	cached := c.cache[dbClient][i]
+	c.cacheMutex.RUnlock()
+	if time.Now().Before(cached.expiresAt) {
+		return cached.metrics, nil
+	}
+
+	q := c.queries[i]
+	rows := []map[string]interface{}{}
+	if err := dbClient.Select(ctx, &rows, q.SQL); err != nil {
+		return nil, err
+	}
+	metrics := make([]prometheus.Metric, 0, len(rows)*len(q.descs))
+	for _, row := range rows {
+		rowMetrics, err := q.metrics(row)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, rowMetrics...)
+	}
+	if q.CacheTTL > 0 {
+		c.cacheMutex.Lock()
+		c.cache[dbClient][i] = cachedResult{metrics: metrics, expiresAt: time.Now().Add(q.CacheTTL)}
+		c.cacheMutex.Unlock()
+	}
+	return metrics, nil
+}
+
+func (q *query) appliesTo(dbClient *db.Client) bool {
+	serverVersion := dbClient.ServerVersionNum()
+	if serverVersion < q.MinServerVersion || (q.MaxServerVersion != 0 && serverVersion >= q.MaxServerVersion) {
+		return false
+	}
+	if len(q.Databases) == 0 {
+		return true
+	}
+	for _, database := range q.Databases {
+		if database == dbClient.Name() || database == dbClient.Database() {
+			return true
+		}
+	}
+	return false
+}
+
+// metrics converts a result row into metrics.
+func (q *query) metrics(row map[string]interface{}) ([]prometheus.Metric, error) {
+	labelValues := make([]string, 0, len(q.labels))
+	for _, label := range q.labels {
+		labelValues = append(labelValues, toLabelValue(row[label]))
+	}
+	metrics := make([]prometheus.Metric, 0, len(q.descs))
+	for _, column := range q.Columns {
+		desc, ok := q.descs[column.Name]
+		if !ok {
+			continue
+		}
+		value, present := row[column.Name]
+		if !present {
+			return nil, fmt.Errorf("column %s is missing", column.Name)
+		}
+		if value == nil {
+			continue
+		}
+		switch column.Usage {
+		case UsageCounter, UsageGauge:
+			f, ok := toFloat64(value)
+			if !ok {
+				return nil, fmt.Errorf("column %s: cannot convert %T to a number", column.Name, value)
+			}
+			valueType := prometheus.GaugeValue
+			if column.Usage == UsageCounter {
+				valueType = prometheus.CounterValue
+			}
+			metrics = append(metrics, prometheus.MustNewConstMetric(desc, valueType, f, labelValues...))
+		case UsageHistogram:
+			metric, err := histogram(desc, column.Name, row, labelValues)
+			if err != nil {
+				return nil, err
+			}
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics, nil
+}
+
+// histogram converts the columns of a histogram into a metric.
+func histogram(desc *prometheus.Desc, name string, row map[string]interface{}, labelValues []string) (prometheus.Metric, error) {
+	upperBounds, ok := toFloat64s(row[name])
+	if !ok {
+		return nil, fmt.Errorf("column %s: cannot convert %T to an array of numbers", name, row[name])
+	}
+	counts, ok := toFloat64s(row[name+"_bucket"])
+	if !ok || len(counts) != len(upperBounds) {
+		return nil, fmt.Errorf("column %s_bucket: must be an array of %d numbers", name, len(upperBounds))
+	}
+	sum, ok := toFloat64(row[name+"_sum"])
+	if !ok {
+		return nil, fmt.Errorf("column %s_sum: cannot convert %T to a number", name, row[name+"_sum"])
+	}
+	count, ok := toFloat64(row[name+"_count"])
+	if !ok {
+		return nil, fmt.Errorf("column %s_count: cannot convert %T to a number", name, row[name+"_count"])
+	}
+	buckets := make(map[float64]uint64, len(upperBounds))
+	for i, upperBound := range upperBounds {
+		buckets[upperBound] = uint64(counts[i])
+	}
+	return prometheus.NewConstHistogram(desc, uint64(count), sum, buckets, labelValues...)
+}
+
+func toFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case time.Time:
+		return float64(v.Unix()), true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	}
+	var f float64
+	return f, assignTo(value, &f)
+}
+
+// toFloat64s converts an array, of floats, numerics or integers, to floats.
+func toFloat64s(value interface{}) ([]float64, bool) {
+	var floats []float64
+	if assignTo(value, &floats) {
+		return floats, true
+	}
+	// Integer arrays only assign to integers.
+	var ints []int64
+	if !assignTo(value, &ints) {
+		return nil, false
+	}
+	floats = make([]float64, len(ints))
+	for i, v := range ints {
+		floats[i] = float64(v)
+	}
+	return floats, true
+}
+
+func toLabelValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339)
+	}
+	return fmt.Sprint(value)
+}
+
+// assignTo assigns a pgtype value, such as a numeric or an array, to dst.
+func assignTo(value interface{}, dst interface{}) bool {
+	if value == nil {
+		return false
+	}
+	// pgtype values implement AssignTo on their pointers.
+	ptr := reflect.New(reflect.TypeOf(value))
+	ptr.Elem().Set(reflect.ValueOf(value))
+	assigner, ok := ptr.Interface().(interface{ AssignTo(dst interface{}) error })
+	return ok && assigner.AssignTo(dst) == nil
+}
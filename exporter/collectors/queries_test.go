@@ -0,0 +1,282 @@
+package collectors
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// pgValue returns the pgtype value set to src, as it is scanned from a row.
+func pgValue(t *testing.T, value pgtype.Value, src interface{}) interface{} {
+	t.Helper()
+	if err := value.Set(src); err != nil {
+		t.Fatalf("setting %T to %v: %v", value, src, err)
+	}
+	return value.Get()
+}
+
+func TestQueryValidate(t *testing.T) {
+	valid := Query{
+		Name:    "pg_replication",
+		SQL:     "SELECT application_name, lag FROM replication",
+		Columns: []Column{{Name: "application_name", Usage: UsageLabel}, {Name: "lag", Usage: UsageGauge}},
+	}
+	tests := []struct {
+		name   string
+		modify func(q *Query)
+		err    string
+	}{
+		{name: "valid", modify: func(q *Query) {}},
+		{name: "invalid name", modify: func(q *Query) { q.Name = "pg-replication" }, err: `name: invalid metric name "pg-replication"`},
+		{name: "missing query", modify: func(q *Query) { q.SQL = "" }, err: "query: is required"},
+		{
+			name:   "invalid label",
+			modify: func(q *Query) { q.Columns[0].Name = "application-name" },
+			err:    `columns[0].name: invalid label name "application-name"`,
+		},
+		{
+			name:   "invalid metric",
+			modify: func(q *Query) { q.Columns[1].Name = "lag seconds" },
+			err:    `columns[1].name: invalid metric name "pg_replication_lag seconds"`,
+		},
+		{name: "unknown usage", modify: func(q *Query) { q.Columns[1].Usage = "SUMMARY" }, err: `columns[1].usage: unknown usage "SUMMARY"`},
+		{
+			name:   "no metrics",
+			modify: func(q *Query) { q.Columns[1].Usage = UsageDiscard },
+			err:    "columns: at least one COUNTER, GAUGE or HISTOGRAM column is required",
+		},
+		{
+			name:   "server versions",
+			modify: func(q *Query) { q.MinServerVersion, q.MaxServerVersion = 130000, 100000 },
+			err:    "max_server_version: must exceed min_server_version",
+		},
+		{name: "min server version only", modify: func(q *Query) { q.MinServerVersion = 130000 }},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := valid
+			q.Columns = append([]Column(nil), valid.Columns...)
+			test.modify(&q)
+			err := q.validate()
+			if test.err == "" && err != nil || test.err != "" && (err == nil || err.Error() != test.err) {
+				t.Errorf("validate() = %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestToFloat64(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name  string
+		value interface{}
+		f     float64
+		ok    bool
+	}{
+		{name: "float64", value: 1.5, f: 1.5, ok: true},
+		{name: "float32", value: float32(0.5), f: 0.5, ok: true},
+		{name: "int64", value: int64(42), f: 42, ok: true},
+		{name: "int32", value: int32(-3), f: -3, ok: true},
+		{name: "int16", value: int16(7), f: 7, ok: true},
+		{name: "bool", value: true, f: 1, ok: true},
+		{name: "time", value: now, f: float64(now.Unix()), ok: true},
+		{name: "numeric string", value: "2.25", f: 2.25, ok: true},
+		{name: "numeric", value: pgValue(t, &pgtype.Numeric{}, "12345.678"), f: 12345.678, ok: true},
+		{name: "NaN numeric", value: pgtype.Numeric{NaN: true, Status: pgtype.Present}, f: math.NaN(), ok: true},
+		{name: "NULL numeric", value: pgtype.Numeric{Status: pgtype.Null}},
+		{name: "NULL", value: nil},
+		{name: "text", value: "replica"},
+		{name: "bytes", value: []byte("1")},
+		{name: "array", value: pgValue(t, &pgtype.Float8Array{}, []float64{1})},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, ok := toFloat64(test.value)
+			if ok != test.ok || ok && f != test.f && !(math.IsNaN(f) && math.IsNaN(test.f)) {
+				t.Errorf("toFloat64(%v) = %v, %t, want %v, %t", test.value, f, ok, test.f, test.ok)
+			}
+		})
+	}
+}
+
+func TestToFloat64s(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  interface{}
+		floats []float64
+		ok     bool
+	}{
+		{name: "float8[]", value: pgValue(t, &pgtype.Float8Array{}, []float64{0.1, 1, 10}), floats: []float64{0.1, 1, 10}, ok: true},
+		{name: "numeric[]", value: pgValue(t, &pgtype.NumericArray{}, []float64{0.5, 2}), floats: []float64{0.5, 2}, ok: true},
+		{name: "bigint[]", value: pgValue(t, &pgtype.Int8Array{}, []int64{3, 5}), floats: []float64{3, 5}, ok: true},
+		{name: "int[]", value: pgValue(t, &pgtype.Int4Array{}, []int32{1}), floats: []float64{1}, ok: true},
+		{name: "empty", value: pgValue(t, &pgtype.Float8Array{}, []float64{}), floats: []float64{}, ok: true},
+		{name: "NULL element", value: pgValue(t, &pgtype.Float8Array{}, []*float64{nil})},
+		{name: "NULL", value: nil},
+		{name: "number", value: 1.5},
+		{name: "text[]", value: pgValue(t, &pgtype.TextArray{}, []string{"1"})},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			floats, ok := toFloat64s(test.value)
+			if ok != test.ok || !equalFloats(floats, test.floats) {
+				t.Errorf("toFloat64s(%v) = %v, %t, want %v, %t", test.value, floats, ok, test.floats, test.ok)
+			}
+		})
+	}
+}
+
+func equalFloats(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestToLabelValue(t *testing.T) {
+	at := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value interface{}
+		label string
+	}{
+		{name: "NULL", value: nil, label: ""},
+		{name: "text", value: "replica", label: "replica"},
+		{name: "bytea", value: []byte("wal"), label: "wal"},
+		{name: "time", value: at, label: "2024-03-01T12:30:00Z"},
+		{name: "int", value: int32(16384), label: "16384"},
+		{name: "bool", value: false, label: "false"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if label := toLabelValue(test.value); label != test.label {
+				t.Errorf("toLabelValue(%v) = %q, want %q", test.value, label, test.label)
+			}
+		})
+	}
+}
+
+func TestQueryMetrics(t *testing.T) {
+	collector := NewQueriesCollector(nil, []Query{{
+		Name: "pg_test",
+		Help: "Test query",
+		SQL:  "SELECT ...",
+		Columns: []Column{
+			{Name: "slot", Usage: UsageLabel},
+			{Name: "active", Usage: UsageGauge},
+			{Name: "bytes", Usage: UsageCounter},
+			{Name: "lag", Usage: UsageHistogram},
+			{Name: "note", Usage: UsageDiscard},
+		},
+	}})
+	q := collector.queries[0]
+	row := func(modify func(row map[string]interface{})) map[string]interface{} {
+		row := map[string]interface{}{
+			"slot":       "replica",
+			"active":     true,
+			"bytes":      pgValue(t, &pgtype.Numeric{}, "1024"),
+			"lag":        pgValue(t, &pgtype.NumericArray{}, []float64{0.1, 1}),
+			"lag_bucket": pgValue(t, &pgtype.Int8Array{}, []int64{2, 5}),
+			"lag_sum":    1.5,
+			"lag_count":  int64(6),
+		}
+		modify(row)
+		return row
+	}
+	tests := []struct {
+		name string
+		row  map[string]interface{}
+		// Expected metrics by name, or the error.
+		metrics []string
+		err     string
+	}{
+		{name: "all columns", row: row(func(map[string]interface{}) {}), metrics: []string{"pg_test_active", "pg_test_bytes", "pg_test_lag"}},
+		{name: "NULL values", row: row(func(row map[string]interface{}) { row["active"], row["lag"] = nil, nil }), metrics: []string{"pg_test_bytes"}},
+		{name: "NULL label", row: row(func(row map[string]interface{}) { row["slot"] = nil }), metrics: []string{"pg_test_active", "pg_test_bytes", "pg_test_lag"}},
+		{name: "missing label", row: row(func(row map[string]interface{}) { delete(row, "slot") }), metrics: []string{"pg_test_active", "pg_test_bytes", "pg_test_lag"}},
+		{name: "missing column", row: row(func(row map[string]interface{}) { delete(row, "bytes") }), err: "column bytes is missing"},
+		{name: "not a number", row: row(func(row map[string]interface{}) { row["active"] = "yes" }), err: "column active: cannot convert string to a number"},
+		{name: "bounds not an array", row: row(func(row map[string]interface{}) { row["lag"] = 1.0 }), err: "column lag: cannot convert float64 to an array of numbers"},
+		{
+			name: "bucket length mismatch",
+			row:  row(func(row map[string]interface{}) { row["lag_bucket"] = pgValue(t, &pgtype.Int8Array{}, []int64{2}) }),
+			err:  "column lag_bucket: must be an array of 2 numbers",
+		},
+		{name: "missing buckets", row: row(func(row map[string]interface{}) { delete(row, "lag_bucket") }), err: "column lag_bucket: must be an array of 2 numbers"},
+		{name: "missing sum", row: row(func(row map[string]interface{}) { delete(row, "lag_sum") }), err: "column lag_sum: cannot convert <nil> to a number"},
+		{name: "NULL count", row: row(func(row map[string]interface{}) { row["lag_count"] = nil }), err: "column lag_count: cannot convert <nil> to a number"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metrics, err := q.metrics(test.row)
+			if test.err != "" {
+				if err == nil || err.Error() != test.err {
+					t.Fatalf("metrics error = %v, want %q", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("metrics: %v", err)
+			}
+			var names []string
+			for _, metric := range metrics {
+				names = append(names, metricName(metric))
+			}
+			if strings.Join(names, ",") != strings.Join(test.metrics, ",") {
+				t.Errorf("metrics = %v, want %v", names, test.metrics)
+			}
+		})
+	}
+}
+
+func TestQueryHistogram(t *testing.T) {
+	collector := NewQueriesCollector(nil, []Query{{
+		Name:    "pg_test",
+		Help:    "Test query",
+		SQL:     "SELECT ...",
+		Columns: []Column{{Name: "slot", Usage: UsageLabel}, {Name: "lag", Usage: UsageHistogram}},
+	}})
+	metrics, err := collector.queries[0].metrics(map[string]interface{}{
+		"slot":       "replica",
+		"lag":        pgValue(t, &pgtype.Float8Array{}, []float64{0.1, 1}),
+		"lag_bucket": pgValue(t, &pgtype.NumericArray{}, []float64{2, 5}),
+		"lag_sum":    pgValue(t, &pgtype.Numeric{}, "1.5"),
+		"lag_count":  int64(6),
+	})
+	if err != nil || len(metrics) != 1 {
+		t.Fatalf("metrics = %v, %v, want a histogram", metrics, err)
+	}
+	var out dto.Metric
+	if err := metrics[0].Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	histogram := out.GetHistogram()
+	if histogram.GetSampleCount() != 6 || histogram.GetSampleSum() != 1.5 {
+		t.Errorf("histogram count = %d, sum = %v, want 6, 1.5", histogram.GetSampleCount(), histogram.GetSampleSum())
+	}
+	buckets := histogram.GetBucket()
+	if len(buckets) != 2 || buckets[0].GetUpperBound() != 0.1 || buckets[0].GetCumulativeCount() != 2 ||
+		buckets[1].GetUpperBound() != 1 || buckets[1].GetCumulativeCount() != 5 {
+		t.Errorf("histogram buckets = %v, want 2 under 0.1 and 5 under 1", buckets)
+	}
+	if labels := out.GetLabel(); len(labels) != 1 || labels[0].GetName() != "slot" || labels[0].GetValue() != "replica" {
+		t.Errorf("histogram labels = %v, want slot=replica", labels)
+	}
+}
+
+// metricName returns the fully-qualified name of the metric.
+func metricName(metric prometheus.Metric) string {
+	desc := metric.Desc().String()
+	start := strings.Index(desc, `fqName: "`) + len(`fqName: "`)
+	return desc[start : start+strings.Index(desc[start:], `"`)]
+}
@@ -0,0 +1,310 @@
+// Package config loads the exporter's targets and options from a YAML or TOML file, e.g.
+//
+//	defaults:
+//	  port: 5432
+//	  application_name: postgres-exporter
+//	  labels:
+//	    env: production
+//	targets:
+//	  - name: orders
+//	    host: orders-db
+//	    database: orders
+//	    collectors: [pg_stat_activity, pg_locks]
+//	  - name: users
+//	    host: users-db
+//	    database: users
+//	    labels:
+//	      team: identity
+//
+// Targets inherit every setting from the defaults, which themselves default to the
+// defaults of the equivalent flags. Labels are merged, whereas collectors are replaced.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+
+	"github.com/odonate/postgres-exporter/exporter"
+	"github.com/odonate/postgres-exporter/exporter/collectors"
+	"github.com/odonate/postgres-exporter/exporter/db"
+)
+
+// collectorOpts are embedded alongside db.Opts, so that their keys are those of the target.
+type collectorOpts = collectors.Opts
+
+// target configures a database to scrape.
+type target struct {
+	db.Opts       `yaml:",inline"`
+	collectorOpts `yaml:",inline"`
+	Labels        map[string]string `yaml:"labels" toml:"labels"`
+	Collectors    []string          `yaml:"collectors" toml:"collectors"`
+}
+
+// copy returns a copy of the target that can be decoded onto without modifying the original.
+func (t target) copy() target {
+	labels := make(map[string]string, len(t.Labels))
+	for name, value := range t.Labels {
+		labels[name] = value
+	}
+	t.Labels = labels
+	t.Collectors = append([]string(nil), t.Collectors...)
+	return t
+}
+
+// config is a decoded configuration file.
+type config struct {
+	defaults target
+	targets  []target
+	// line returns the line of the key of the target at index i, or of the defaults if i is -1.
+	// It returns 0 if unknown.
+	line func(i int, key string) int
+}
+
+// Load reads the configuration file at path, choosing its format by extension.
+func Load(path string) (exporter.Opts, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return exporter.Opts{}, fmt.Errorf("reading config: %w", err)
+	}
+	var cfg *config
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		cfg, err = decodeYAML(data)
+	case ".toml":
+		cfg, err = decodeTOML(data)
+	default:
+		return exporter.Opts{}, fmt.Errorf("unsupported config format %q", ext)
+	}
+	if err != nil {
+		return exporter.Opts{}, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return exporter.Opts{}, fmt.Errorf("validating %s: %w", path, err)
+	}
+	return cfg.opts(), nil
+}
+
+// newDefaults returns a target holding the defaults of the db flags.
+func newDefaults() (target, error) {
+	defaults := target{Labels: map[string]string{}}
+	if err := setFlagDefaults(reflect.ValueOf(&defaults.Opts).Elem()); err != nil {
+		return target{}, err
+	}
+	if err := setFlagDefaults(reflect.ValueOf(&defaults.collectorOpts).Elem()); err != nil {
+		return target{}, err
+	}
+	return defaults, nil
+}
+
+func (c *config) opts() exporter.Opts {
+	opts := exporter.Opts{
+		Targets:       make([]exporter.Target, 0, len(c.targets)),
+		ProbeDBOpts:   &c.defaults.Opts,
+		CollectorOpts: &c.defaults.collectorOpts,
+	}
+	for _, t := range c.targets {
+		collectorOpts := t.collectorOpts
+		opts.Targets = append(opts.Targets, exporter.Target{
+			DBOpts:        t.Opts,
+			Labels:        t.Labels,
+			Collectors:    t.Collectors,
+			CollectorOpts: &collectorOpts,
+		})
+	}
+	return opts
+}
+
+// keyError points to the offending key of a configuration file.
+type keyError struct {
+	line int
+	path string
+	msg  string
+}
+
+func (e *keyError) Error() string {
+	if e.line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", e.line, e.path, e.msg)
+	}
+	return fmt.Sprintf("%s: %s", e.path, e.msg)
+}
+
+func (c *config) validate() error {
+	if len(c.targets) == 0 {
+		return &keyError{path: "targets", msg: "at least one target is required"}
+	}
+	if err := c.validateTarget(-1, c.defaults); err != nil {
+		return err
+	}
+	names := make(map[string]struct{}, len(c.targets))
+	for i, t := range c.targets {
+		if err := c.validateTarget(i, t); err != nil {
+			return err
+		}
+		if t.Name == "" {
+			return c.errorf(i, "name", "is required")
+		}
+		if _, ok := names[t.Name]; ok {
+			return c.errorf(i, "name", "duplicate target %q", t.Name)
+		}
+		names[t.Name] = struct{}{}
+		if t.Host == "" {
+			return c.errorf(i, "host", "is required")
+		}
+		if t.ApplicationName == "" {
+			return c.errorf(i, "application_name", "is required")
+		}
+	}
+	return nil
+}
+
+func (c *config) validateTarget(i int, t target) error {
+	if err := c.validateChoices(i, reflect.ValueOf(t.Opts)); err != nil {
+		return err
+	}
+	if t.Port < 1 || t.Port > 65535 {
+		return c.errorf(i, "port", "%d is not between 1 and 65535", t.Port)
+	}
+	if t.PoolMaxConns < 1 {
+		return c.errorf(i, "pool_max_conns", "must be positive")
+	}
+	if t.PoolMinConns > t.PoolMaxConns {
+		return c.errorf(i, "pool_min_conns", "%d exceeds pool_max_conns of %d", t.PoolMinConns, t.PoolMaxConns)
+	}
+	if (t.SSLCert == "") != (t.SSLKey == "") {
+		return c.errorf(i, "ssl_key", "ssl_cert and ssl_key must be given together")
+	}
+	// Defaults may leave the certificates to each target.
+	if i >= 0 && t.AuthMechanism == db.AuthMechanismClientCertificates && t.SSLCert == "" {
+		return c.errorf(i, "ssl_cert", "is required by the %s auth mechanism", t.AuthMechanism)
+	}
+	reserved := exporter.ReservedLabelNames()
+	for name := range t.Labels {
+		if !model.LabelName(name).IsValid() || strings.HasPrefix(name, model.ReservedLabelPrefix) {
+			return c.errorf(i, "labels", "invalid label name %q", name)
+		}
+		if contains(reserved, name) {
+			return c.errorf(i, "labels", "label %q is reserved by the exporter", name)
+		}
+	}
+	seen := make(map[string]struct{}, len(t.Collectors))
+	for j, name := range t.Collectors {
+		key := fmt.Sprintf("collectors[%d]", j)
+		if _, ok := collectors.LookupFactory(name, t.collectorOpts); !ok {
+			return c.errorf(i, key, "unknown collector %q", name)
+		}
+		if _, ok := seen[name]; ok {
+			return c.errorf(i, key, "duplicate collector %q", name)
+		}
+		seen[name] = struct{}{}
+	}
+	return nil
+}
+
+// validateChoices checks that every db option restricted to a set of choices holds one of them.
+func (c *config) validateChoices(i int, opts reflect.Value) error {
+	for j := 0; j < opts.NumField(); j++ {
+		field := opts.Type().Field(j)
+		choices := tagValues(field.Tag, "choice")
+		if len(choices) == 0 || opts.Field(j).Kind() != reflect.String {
+			continue
+		}
+		value := opts.Field(j).String()
+		if !contains(choices, value) {
+			return c.errorf(i, field.Tag.Get("yaml"), "%q is not one of %s", value, strings.Join(choices, ", "))
+		}
+	}
+	return nil
+}
+
+func (c *config) errorf(i int, key, format string, args ...interface{}) error {
+	path := "defaults"
+	if i >= 0 {
+		path = fmt.Sprintf("targets[%d]", i)
+	}
+	// Keys of list elements, e.g. collectors[1], are located by their list.
+	line := c.line(i, strings.SplitN(key, "[", 2)[0])
+	return &keyError{line: line, path: path + "." + key, msg: fmt.Sprintf(format, args...)}
+}
+
+// setFlagDefaults sets every field of the struct to the value of its default tag.
+func setFlagDefaults(v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		value, ok := field.Tag.Lookup("default")
+		if !ok {
+			continue
+		}
+		switch dst := v.Field(i); {
+		case dst.Type() == reflect.TypeOf(time.Duration(0)):
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("default of %s: %w", field.Name, err)
+			}
+			dst.SetInt(int64(d))
+		case dst.Kind() == reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("default of %s: %w", field.Name, err)
+			}
+			dst.SetInt(int64(n))
+		case dst.Kind() == reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("default of %s: %w", field.Name, err)
+			}
+			dst.SetBool(b)
+		case dst.Kind() == reflect.String:
+			dst.SetString(value)
+		default:
+			return fmt.Errorf("default of %s: unsupported type %s", field.Name, dst.Type())
+		}
+	}
+	return nil
+}
+
+// tagValues returns every value of a struct tag key that may be repeated, such as choice.
+func tagValues(tag reflect.StructTag, key string) []string {
+	var values []string
+	for tag != "" {
+		tag = reflect.StructTag(strings.TrimLeft(string(tag), " "))
+		i := strings.Index(string(tag), ":\"")
+		if i < 1 {
+			break
+		}
+		name := string(tag[:i])
+		rest := string(tag[i+1:])
+		j := 1
+		for j < len(rest) && rest[j] != '"' {
+			if rest[j] == '\\' {
+				j++
+			}
+			j++
+		}
+		if j >= len(rest) {
+			break
+		}
+		if name == key {
+			if value, err := strconv.Unquote(rest[:j+1]); err == nil {
+				values = append(values, value)
+			}
+		}
+		tag = reflect.StructTag(rest[j+1:])
+	}
+	return values
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,271 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/odonate/postgres-exporter/exporter"
+)
+
+const testYAML = `defaults:
+  port: 5433
+  application_name: postgres-exporter
+  statement_timeout: 10s
+  size_relations: 20
+  labels:
+    env: production
+targets:
+  - name: orders
+    host: orders-db
+    database: orders
+    collectors: [pg_stat_activity, pg_locks]
+  - name: users
+    host: users-db
+    database: users
+    port: 6432
+    size_relations: 5
+    labels:
+      team: identity
+`
+
+const testTOML = `[defaults]
+port = 5433
+application_name = "postgres-exporter"
+statement_timeout = "10s"
+size_relations = 20
+
+[defaults.labels]
+env = "production"
+
+[[targets]]
+name = "orders"
+host = "orders-db"
+database = "orders"
+collectors = ["pg_stat_activity", "pg_locks"]
+
+[[targets]]
+name = "users"
+host = "users-db"
+database = "users"
+port = 6432
+size_relations = 5
+
+[targets.labels]
+team = "identity"
+`
+
+// writeConfig writes the data to a file of the given name in a temporary directory.
+func writeConfig(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "config.yaml", data: testYAML},
+		{name: "config.toml", data: testTOML},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			opts, err := Load(writeConfig(t, test.name, test.data))
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			checkOpts(t, opts)
+		})
+	}
+}
+
+func checkOpts(t *testing.T, opts exporter.Opts) {
+	t.Helper()
+	if len(opts.Targets) != 2 {
+		t.Fatalf("got %d targets, want 2", len(opts.Targets))
+	}
+	if opts.ProbeDBOpts.Port != 5433 || opts.CollectorOpts.SizeRelations != 20 {
+		t.Errorf("defaults not applied to the probe opts")
+	}
+
+	orders, users := opts.Targets[0], opts.Targets[1]
+	if orders.DBOpts.Name != "orders" || orders.DBOpts.Host != "orders-db" || orders.DBOpts.Database != "orders" {
+		t.Errorf("orders target = %+v", orders.DBOpts)
+	}
+	// Settings are inherited from the defaults, which default to the flag defaults.
+	if orders.DBOpts.Port != 5433 || orders.DBOpts.ApplicationName != "postgres-exporter" {
+		t.Errorf("orders target did not inherit the defaults: %+v", orders.DBOpts)
+	}
+	if orders.DBOpts.StatementTimeout != 10*time.Second || orders.DBOpts.User != "postgres" || orders.DBOpts.PoolMaxConns != 10 {
+		t.Errorf("orders target did not inherit the flag defaults: %+v", orders.DBOpts)
+	}
+	if users.DBOpts.Port != 6432 {
+		t.Errorf("users port = %d, want 6432", users.DBOpts.Port)
+	}
+
+	// Collector options are inherited and overridden like the db options.
+	if orders.CollectorOpts.SizeRelations != 20 || orders.CollectorOpts.WraparoundRelations != 10 {
+		t.Errorf("orders collector opts = %+v", *orders.CollectorOpts)
+	}
+	if users.CollectorOpts.SizeRelations != 5 {
+		t.Errorf("users size_relations = %d, want 5", users.CollectorOpts.SizeRelations)
+	}
+
+	// Labels are merged, whereas collectors are replaced.
+	if want := map[string]string{"env": "production"}; !reflect.DeepEqual(orders.Labels, want) {
+		t.Errorf("orders labels = %v, want %v", orders.Labels, want)
+	}
+	if want := map[string]string{"env": "production", "team": "identity"}; !reflect.DeepEqual(users.Labels, want) {
+		t.Errorf("users labels = %v, want %v", users.Labels, want)
+	}
+	if want := []string{"pg_stat_activity", "pg_locks"}; !reflect.DeepEqual(orders.Collectors, want) {
+		t.Errorf("orders collectors = %v, want %v", orders.Collectors, want)
+	}
+	if len(users.Collectors) != 0 {
+		t.Errorf("users collectors = %v, want the defaults", users.Collectors)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		data string
+		err  string
+	}{
+		{
+			name: "no targets",
+			file: "config.yaml",
+			data: "defaults:\n  application_name: app\n",
+			err:  "validating %s: targets: at least one target is required",
+		},
+		{
+			name: "unknown key",
+			file: "config.yaml",
+			data: "targets:\n  - name: orders\n    hots: orders-db\n",
+			err:  "decoding %s: line 3: targets[0].hots: unknown key",
+		},
+		{
+			name: "unknown defaults key",
+			file: "config.yaml",
+			data: "defaults:\n  prot: 5432\ntargets:\n  - name: orders\n",
+			err:  "decoding %s: line 2: defaults.prot: unknown key",
+		},
+		{
+			name: "target not a mapping",
+			file: "config.yaml",
+			data: "targets:\n  - orders\n",
+			err:  "decoding %s: line 2: targets[0]: must be a mapping",
+		},
+		{
+			name: "missing name",
+			file: "config.yaml",
+			data: "targets:\n  - host: orders-db\n    application_name: app\n",
+			err:  "validating %s: line 2: targets[0].name: is required",
+		},
+		{
+			name: "duplicate name",
+			file: "config.yaml",
+			data: "defaults:\n  application_name: app\ntargets:\n  - name: orders\n    host: a\n  - name: orders\n    host: b\n",
+			err:  "validating %s: line 6: targets[1].name: duplicate target \"orders\"",
+		},
+		{
+			name: "missing application name",
+			file: "config.yaml",
+			data: "targets:\n  - name: orders\n    host: orders-db\n",
+			err:  "validating %s: line 2: targets[0].application_name: is required",
+		},
+		{
+			name: "port out of range",
+			file: "config.yaml",
+			data: "defaults:\n  application_name: app\ntargets:\n  - name: orders\n    host: orders-db\n    port: 70000\n",
+			err:  "validating %s: line 6: targets[0].port: 70000 is not between 1 and 65535",
+		},
+		{
+			name: "invalid defaults port",
+			file: "config.yaml",
+			data: "defaults:\n  port: 0\ntargets:\n  - name: orders\n",
+			err:  "validating %s: line 2: defaults.port: 0 is not between 1 and 65535",
+		},
+		{
+			name: "pool sizes",
+			file: "config.yaml",
+			data: "defaults:\n  application_name: app\ntargets:\n  - name: orders\n    host: orders-db\n    pool_min_conns: 20\n",
+			err:  "validating %s: line 6: targets[0].pool_min_conns: 20 exceeds pool_max_conns of 10",
+		},
+		{
+			name: "invalid choice",
+			file: "config.yaml",
+			data: "defaults:\n  application_name: app\ntargets:\n  - name: orders\n    host: orders-db\n    ssl_mode: always\n",
+			err:  "validating %s: line 6: targets[0].ssl_mode: \"always\" is not one of disable, allow, prefer, require, verify-ca, verify-full",
+		},
+		{
+			name: "certificate without key",
+			file: "config.yaml",
+			data: "defaults:\n  application_name: app\ntargets:\n  - name: orders\n    host: orders-db\n    ssl_cert: client.crt\n",
+			err:  "validating %s: line 4: targets[0].ssl_key: ssl_cert and ssl_key must be given together",
+		},
+		{
+			name: "invalid label",
+			file: "config.yaml",
+			data: "defaults:\n  application_name: app\ntargets:\n  - name: orders\n    host: orders-db\n    labels:\n      __team: identity\n",
+			err:  "validating %s: line 6: targets[0].labels: invalid label name \"__team\"",
+		},
+		{
+			name: "reserved label",
+			file: "config.yaml",
+			data: "defaults:\n  application_name: app\n  labels:\n    datname: orders\ntargets:\n  - name: orders\n    host: orders-db\n",
+			err:  "validating %s: line 3: defaults.labels: label \"datname\" is reserved by the exporter",
+		},
+		{
+			name: "unknown collector",
+			file: "config.yaml",
+			data: "defaults:\n  application_name: app\ntargets:\n  - name: orders\n    host: orders-db\n  - name: users\n    host: users-db\n    collectors: [pg_locks, pg_nope]\n",
+			err:  "validating %s: line 8: targets[1].collectors[1]: unknown collector \"pg_nope\"",
+		},
+		{
+			name: "duplicate collector",
+			file: "config.yaml",
+			data: "defaults:\n  application_name: app\ntargets:\n  - name: orders\n    host: orders-db\n    collectors: [pg_locks, pg_locks]\n",
+			err:  "validating %s: line 6: targets[0].collectors[1]: duplicate collector \"pg_locks\"",
+		},
+		{
+			name: "toml unknown key",
+			file: "config.toml",
+			data: "[[targets]]\nname = \"orders\"\nhots = \"orders-db\"\n",
+			err:  "decoding %s: targets.hots: unknown key",
+		},
+		{
+			name: "toml validation",
+			file: "config.toml",
+			data: "[defaults]\napplication_name = \"app\"\n\n[[targets]]\nname = \"orders\"\nhost = \"orders-db\"\ncollectors = [\"pg_nope\"]\n",
+			err:  "validating %s: targets[0].collectors[0]: unknown collector \"pg_nope\"",
+		},
+		{
+			name: "unsupported format",
+			file: "config.json",
+			data: "{}",
+			err:  "unsupported config format \".json\"",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := writeConfig(t, test.file, test.data)
+			_, err := Load(path)
+			if err == nil {
+				t.Fatal("Load succeeded")
+			}
+			if want := strings.ReplaceAll(test.err, "%s", path); err.Error() != want {
+				t.Errorf("Load error = %q, want %q", err, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+)
+
+func decodeTOML(data []byte) (*config, error) {
+	var root struct {
+		Defaults toml.Primitive   `toml:"defaults"`
+		Targets  []toml.Primitive `toml:"targets"`
+	}
+	metadata, err := toml.Decode(string(data), &root)
+	if err != nil {
+		return nil, err
+	}
+
+	defaults, err := newDefaults()
+	if err != nil {
+		return nil, err
+	}
+	if err := metadata.PrimitiveDecode(root.Defaults, &defaults); err != nil {
+		return nil, fmt.Errorf("defaults: %w", err)
+	}
+	cfg := &config{
+		defaults: defaults,
+		targets:  make([]target, 0, len(root.Targets)),
+		// TOML metadata does not record the position of keys.
+		line: func(int, string) int { return 0 },
+	}
+	for i, primitive := range root.Targets {
+		t := defaults.copy()
+		if err := metadata.PrimitiveDecode(primitive, &t); err != nil {
+			return nil, fmt.Errorf("targets[%d]: %w", i, err)
+		}
+		cfg.targets = append(cfg.targets, t)
+	}
+	// Every key has been decoded by now, so the remaining ones are unknown.
+	if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+		return nil, &keyError{path: undecoded[0].String(), msg: "unknown key"}
+	}
+	return cfg, nil
+}
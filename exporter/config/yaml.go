@@ -0,0 +1,99 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+func decodeYAML(data []byte) (*config, error) {
+	var root struct {
+		Defaults yaml.Node   `yaml:"defaults"`
+		Targets  []yaml.Node `yaml:"targets"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&root); err != nil {
+		return nil, err
+	}
+
+	defaults, err := newDefaults()
+	if err != nil {
+		return nil, err
+	}
+	if err := decodeYAMLTarget(&root.Defaults, "defaults", &defaults); err != nil {
+		return nil, err
+	}
+	cfg := &config{
+		defaults: defaults,
+		targets:  make([]target, 0, len(root.Targets)),
+		line: func(i int, key string) int {
+			if i < 0 {
+				return yamlKeyLine(&root.Defaults, key)
+			}
+			return yamlKeyLine(&root.Targets[i], key)
+		},
+	}
+	for i := range root.Targets {
+		t := defaults.copy()
+		if err := decodeYAMLTarget(&root.Targets[i], fmt.Sprintf("targets[%d]", i), &t); err != nil {
+			return nil, err
+		}
+		cfg.targets = append(cfg.targets, t)
+	}
+	return cfg, nil
+}
+
+// decodeYAMLTarget decodes the node onto the target, rejecting unknown keys.
+func decodeYAMLTarget(node *yaml.Node, path string, t *target) error {
+	if node.Kind == 0 {
+		// The key is absent.
+		return nil
+	}
+	if node.Kind != yaml.MappingNode {
+		return &keyError{line: node.Line, path: path, msg: "must be a mapping"}
+	}
+	known := yamlKeys(reflect.TypeOf(*t))
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if _, ok := known[key.Value]; !ok {
+			return &keyError{line: key.Line, path: path + "." + key.Value, msg: "unknown key"}
+		}
+	}
+	if err := node.Decode(t); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// yamlKeys returns the keys of the struct, including those of inlined structs.
+func yamlKeys(t reflect.Type) map[string]struct{} {
+	keys := make(map[string]struct{}, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if strings.HasSuffix(field.Tag.Get("yaml"), ",inline") {
+			for key := range yamlKeys(field.Type) {
+				keys[key] = struct{}{}
+			}
+			continue
+		}
+		if name != "" && name != "-" {
+			keys[name] = struct{}{}
+		}
+	}
+	return keys
+}
+
+// yamlKeyLine returns the line of the key within the mapping, or of the mapping itself.
+func yamlKeyLine(node *yaml.Node, key string) int {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i].Line
+		}
+	}
+	return node.Line
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+// credentialExpiryMargin is how long before they expire credentials are fetched afresh,
+// so that connections are not made with credentials about to expire.
+const credentialExpiryMargin = 30 * time.Second
+
+// Credentials authenticate new connections until they expire.
+type Credentials struct {
+	// User overrides the user of the opts, unless empty.
+	User     string
+	Password string
+	// ExpiresAt is when the credentials are fetched afresh, zero being once they fail to authenticate.
+	ExpiresAt time.Time
+}
+
+// CredentialProvider provides the credentials of new connections, such as short-lived auth tokens.
+type CredentialProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// StaticProvider provides fixed credentials.
+type StaticProvider struct {
+	User     string
+	Password string
+}
+
+// Credentials implements the CredentialProvider.
+func (p StaticProvider) Credentials(context.Context) (Credentials, error) {
+	return Credentials{User: p.User, Password: p.Password}, nil
+}
+
+// FileProvider provides the password held in a file.
+type FileProvider struct {
+	Path string
+}
+
+// Credentials implements the CredentialProvider.
+func (p FileProvider) Credentials(context.Context) (Credentials, error) {
+	password, err := readPasswordFile(p.Path)
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{Password: password}, nil
+}
+
+// CommandProvider provides the credentials printed by a shell command, e.g. a cloud CLI
+// generating auth tokens. The command prints either the password, or a JSON object such as
+// {"user": "exporter", "password": "token", "expires_at": "2023-01-02T15:04:05Z"}.
+type CommandProvider struct {
+	Command string
+	// TTL of credentials printed without an expiry, zero being until they fail to authenticate.
+	TTL time.Duration
+}
+
+// Credentials implements the CredentialProvider.
+func (p CommandProvider) Credentials(ctx context.Context) (Credentials, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", p.Command)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return Credentials{}, fmt.Errorf("running credential command: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	output := strings.TrimSpace(stdout.String())
+	var credentials Credentials
+	if strings.HasPrefix(output, "{") {
+		var printed struct {
+			User      string    `json:"user"`
+			Password  string    `json:"password"`
+			ExpiresAt time.Time `json:"expires_at"`
+		}
+		if err := json.Unmarshal([]byte(output), &printed); err != nil {
+			return Credentials{}, fmt.Errorf("parsing credential command output: %w", err)
+		}
+		credentials = Credentials(printed)
+	} else {
+		credentials.Password = output
+	}
+	if credentials.ExpiresAt.IsZero() && p.TTL > 0 {
+		credentials.ExpiresAt = time.Now().Add(p.TTL)
+	}
+	return credentials, nil
+}
+
+// credentialProvider returns the provider of the credentials of the opts, or nil if the
+// client certificate authenticates the user.
+func credentialProvider(opts Opts) CredentialProvider {
+	switch {
+	case opts.AuthMechanism == AuthMechanismClientCertificates:
+		return nil
+	case opts.CredentialProvider != nil:
+		return opts.CredentialProvider
+	case opts.CredentialCommand != "":
+		return CommandProvider{Command: opts.CredentialCommand, TTL: opts.CredentialTTL}
+	}
+	return passwordProvider{opts: opts}
+}
+
+// cachedCredentials caches the credentials of a provider until they are about to expire,
+// or fail to authenticate, so that rotated secrets are picked up without restarting.
+type cachedCredentials struct {
+	provider    CredentialProvider
+	name        string
+	mutex       sync.Mutex
+	credentials *Credentials
+}
+
+func newCachedCredentials(name string, provider CredentialProvider) *cachedCredentials {
+	return &cachedCredentials{provider: provider, name: name}
+}
+
+// get returns the cached credentials, fetching them afresh if need be.
+func (c *cachedCredentials) get(ctx context.Context) (Credentials, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.credentials != nil && (c.credentials.ExpiresAt.IsZero() || time.Until(c.credentials.ExpiresAt) > credentialExpiryMargin) {
+		return *c.credentials, nil
+	}
+	credentials, err := c.provider.Credentials(ctx)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("%s credentials: %w", c.name, err)
+	}
+	c.credentials = &credentials
+	return credentials, nil
+}
+
+// invalidate drops the cached credentials if the error is an authentication failure,
+// so that they are fetched afresh for the next connection.
+func (c *cachedCredentials) invalidate(err error) {
+	var pgErr *pgconn.PgError
+	if c == nil || !errors.As(err, &pgErr) || (pgErr.Code != codeInvalidPassword && pgErr.Code != codeInvalidAuthorization) {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.credentials != nil {
+		log.Infof("%s authentication failed, fetching credentials afresh", c.name)
+		c.credentials = nil
+	}
+}
@@ -0,0 +1,127 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+// fakeProvider provides the credentials in turn, rotating them on every call.
+type fakeProvider struct {
+	credentials []Credentials
+	err         error
+	calls       int
+}
+
+func (p *fakeProvider) Credentials(context.Context) (Credentials, error) {
+	p.calls++
+	if p.err != nil {
+		return Credentials{}, p.err
+	}
+	return p.credentials[(p.calls-1)%len(p.credentials)], nil
+}
+
+func TestCachedCredentialsRotation(t *testing.T) {
+	provider := &fakeProvider{credentials: []Credentials{{Password: "first"}, {User: "rotated", Password: "second"}}}
+	credentials := newCachedCredentials("test", provider)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		c, err := credentials.get(ctx)
+		if err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		if c.Password != "first" {
+			t.Errorf("password = %q, want first", c.Password)
+		}
+	}
+	if provider.calls != 1 {
+		t.Errorf("provider called %d times, want 1", provider.calls)
+	}
+
+	// Errors other than authentication failures keep the credentials.
+	credentials.invalidate(&pgconn.PgError{Code: "57P01"})
+	credentials.invalidate(errors.New("connection refused"))
+	if c, _ := credentials.get(ctx); c.Password != "first" || provider.calls != 1 {
+		t.Errorf("credentials refetched after a non-authentication error")
+	}
+
+	credentials.invalidate(fmt.Errorf("connecting: %w", &pgconn.PgError{Code: codeInvalidPassword}))
+	c, err := credentials.get(ctx)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if c.User != "rotated" || c.Password != "second" {
+		t.Errorf("credentials = %+v, want the rotated ones", c)
+	}
+	if provider.calls != 2 {
+		t.Errorf("provider called %d times, want 2", provider.calls)
+	}
+}
+
+func TestCachedCredentialsExpiry(t *testing.T) {
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		calls     int
+	}{
+		{name: "no expiry", calls: 1},
+		{name: "valid", expiresAt: time.Now().Add(time.Hour), calls: 1},
+		{name: "within margin", expiresAt: time.Now().Add(credentialExpiryMargin / 2), calls: 2},
+		{name: "expired", expiresAt: time.Now().Add(-time.Minute), calls: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			provider := &fakeProvider{credentials: []Credentials{{Password: "token", ExpiresAt: test.expiresAt}}}
+			credentials := newCachedCredentials("test", provider)
+			for i := 0; i < 2; i++ {
+				if _, err := credentials.get(context.Background()); err != nil {
+					t.Fatalf("get: %v", err)
+				}
+			}
+			if provider.calls != test.calls {
+				t.Errorf("provider called %d times, want %d", provider.calls, test.calls)
+			}
+		})
+	}
+}
+
+func TestCachedCredentialsError(t *testing.T) {
+	errProvider := errors.New("token service unavailable")
+	provider := &fakeProvider{err: errProvider}
+	credentials := newCachedCredentials("test", provider)
+
+	for i := 1; i <= 2; i++ {
+		_, err := credentials.get(context.Background())
+		if !errors.Is(err, errProvider) {
+			t.Fatalf("get error = %v, want %v", err, errProvider)
+		}
+		if want := "test credentials: token service unavailable"; err.Error() != want {
+			t.Errorf("get error = %q, want %q", err, want)
+		}
+		// Failures are not cached, the provider is asked again.
+		if provider.calls != i {
+			t.Errorf("provider called %d times, want %d", provider.calls, i)
+		}
+	}
+
+	provider.err = nil
+	provider.credentials = []Credentials{{Password: "recovered"}}
+	c, err := credentials.get(context.Background())
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if c.Password != "recovered" {
+		t.Errorf("password = %q, want recovered", c.Password)
+	}
+}
+
+func TestCachedCredentialsNil(t *testing.T) {
+	var credentials *cachedCredentials
+	// Clients authenticated by client certificates have no credentials to invalidate.
+	credentials.invalidate(&pgconn.PgError{Code: codeInvalidPassword})
+}
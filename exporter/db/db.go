@@ -2,58 +2,214 @@ package db
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/georgysavva/scany/pgxscan"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 
+	"github.com/odonate/postgres-exporter/exporter/db/model"
 	"github.com/odonate/postgres-exporter/exporter/logging"
 )
 
 var log = logging.NewLogger()
 
+// Waits between attempts at connecting, doubling from connectRetryWait up to maxConnectRetryWait.
 const (
-	connectRetryWait = 2 * time.Second
+	connectRetryWait    = 2 * time.Second
+	maxConnectRetryWait = time.Minute
 )
 
+// ErrNotConnected is returned by queries of clients yet to connect.
+var ErrNotConnected = errors.New("not connected")
+
 // Client to PostgreSQL server.
 type Client struct {
 	opts      Opts
 	pool      *pgxpool.Pool
 	txOptions pgx.TxOptions
+	// credentials authenticate new connections, nil if the client certificate does.
+	credentials *cachedCredentials
+
+	// connected is closed once connected, after which the server is known.
+	connected    chan struct{}
+	connectErr   error
+	connectMutex sync.Mutex
+	// Stop connecting lazily, nil unless lazy.
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// Detected at connect time.
+	serverVersion model.ServerVersion
+	// Detected at connect time, and afresh by DetectExtensions.
+	extensions      map[string]string
+	extensionsMutex sync.RWMutex
 }
 
-// New instantiates and returns a new DB.
+// New instantiates and returns a new DB, detecting the version and extensions of the server.
+// Connecting is retried up to MaxConnectionRetries times, with capped exponential backoff.
 func New(ctx context.Context, opts Opts) (*Client, error) {
-	var pool *pgxpool.Pool
-	var err error
-	dsn := DSN(opts)
+	client, err := newClient(opts)
+	if err != nil {
+		return nil, err
+	}
+	for attempt := 0; ; attempt++ {
+		err := client.connect(ctx)
+		if err == nil {
+			return client, nil
+		}
+		if opts.MaxConnectionRetries != -1 && attempt >= opts.MaxConnectionRetries {
+			client.pool.Close()
+			return nil, err
+		}
+		if !sleep(ctx, connectBackoff(attempt)) {
+			client.pool.Close()
+			return nil, err
+		}
+	}
+}
 
-	poolConfig, err := pgxpool.ParseConfig(dsn)
+// NewLazy instantiates and returns a new DB without waiting for it to connect. Connecting is
+// retried in the background, with capped exponential backoff, until it succeeds or the client
+// is closed. Until then, queries fail with ErrNotConnected.
+func NewLazy(opts Opts) (*Client, error) {
+	client, err := newClient(opts)
 	if err != nil {
 		return nil, err
 	}
+	ctx, cancel := context.WithCancel(context.Background())
+	client.cancel = cancel
+	client.done = make(chan struct{})
+	go client.connectLazily(ctx)
+	return client, nil
+}
 
-	for i := 0; i <= opts.MaxConnectionRetries || opts.MaxConnectionRetries == -1; i++ {
-		pool, err = pgxpool.ConnectConfig(ctx, poolConfig)
-		if err != nil {
-			if err == ctx.Err() {
-				return nil, err
+// newClient instantiates a client with a pool that connects on demand.
+func newClient(opts Opts) (*Client, error) {
+	if err := checkAuthParams(opts); err != nil {
+		return nil, err
+	}
+	poolConfig, err := pgxpool.ParseConfig(DSN(opts))
+	if err != nil {
+		return nil, err
+	}
+	poolConfig.LazyConnect = true
+	poolConfig.ConnConfig.ConnectTimeout = opts.ConnectTimeout
+	configureTLS(&poolConfig.ConnConfig.Config, opts)
+	var credentials *cachedCredentials
+	if provider := credentialProvider(opts); provider != nil {
+		credentials = newCachedCredentials(opts.TargetName(), provider)
+		poolConfig.BeforeConnect = func(ctx context.Context, config *pgx.ConnConfig) error {
+			c, err := credentials.get(ctx)
+			if err != nil {
+				return err
 			}
-			if i < opts.MaxConnectionRetries && opts.MaxConnectionRetries != 0 {
-				time.Sleep(connectRetryWait)
+			if c.User != "" {
+				config.User = c.User
 			}
-			continue
+			config.Password = c.Password
+			return nil
 		}
-		client := &Client{
-			opts: opts,
-			pool: pool,
+	}
+	// Lazy pools only fail on invalid configs.
+	pool, err := pgxpool.ConnectConfig(context.Background(), poolConfig)
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{
+		opts:        opts,
+		pool:        pool,
+		credentials: credentials,
+		connected:   make(chan struct{}),
+	}
+	client.setTxOptions(opts)
+	return client, nil
+}
+
+// connect connects to the server and detects its version and extensions.
+func (c *Client) connect(ctx context.Context) error {
+	err := c.pool.Ping(ctx)
+	if err == nil {
+		err = c.detectServer(ctx)
+	}
+	c.connectMutex.Lock()
+	defer c.connectMutex.Unlock()
+	c.connectErr = err
+	if err != nil {
+		c.credentials.invalidate(err)
+		return err
+	}
+	close(c.connected)
+	return nil
+}
+
+func (c *Client) connectLazily(ctx context.Context) {
+	defer close(c.done)
+	for attempt := 0; ; attempt++ {
+		err := c.connect(ctx)
+		if err == nil {
+			log.Infof("%s connected", c.Name())
+			return
 		}
-		client.setTxOptions(opts)
-		return client, nil
+		if ctx.Err() != nil {
+			return
+		}
+		wait := connectBackoff(attempt)
+		log.Errorf("%s connecting failed, retrying in %s: %s", c.Name(), wait, err)
+		if !sleep(ctx, wait) {
+			return
+		}
+	}
+}
+
+// connectBackoff returns the wait following the attempt at connecting, numbered from 0.
+func connectBackoff(attempt int) time.Duration {
+	wait := connectRetryWait
+	for i := 0; i < attempt && wait < maxConnectRetryWait; i++ {
+		wait *= 2
+	}
+	if wait > maxConnectRetryWait {
+		return maxConnectRetryWait
+	}
+	return wait
+}
+
+// sleep waits for the duration unless the context is done first, returning whether it waited.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
 	}
-	return nil, err
+}
+
+// Connected returns whether the client has connected, and detected the server.
+func (c *Client) Connected() bool {
+	select {
+	case <-c.connected:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkConnected returns ErrNotConnected, along with the reason, until connected.
+func (c *Client) checkConnected() error {
+	if c.Connected() {
+		return nil
+	}
+	c.connectMutex.Lock()
+	defer c.connectMutex.Unlock()
+	if c.connectErr != nil {
+		return fmt.Errorf("%w: %s", ErrNotConnected, c.connectErr)
+	}
+	return ErrNotConnected
 }
 
 func (c *Client) setTxOptions(opts Opts) {
@@ -77,16 +233,83 @@ func defaultIsolationLevel(isoLevel string) pgx.TxIsoLevel {
 	return pgx.RepeatableRead
 }
 
+// Name returns the name identifying the target this client connects to.
+func (c *Client) Name() string {
+	return c.opts.TargetName()
+}
+
+// Database returns the name of the database this client connects to.
+func (c *Client) Database() string {
+	return c.opts.Database
+}
+
 // CheckConnection acquires a connection from the pool and executes an empty sql statement over it.
 func (c *Client) CheckConnection(ctx context.Context) error {
-	return c.pool.Ping(ctx)
+	err := c.pool.Ping(ctx)
+	c.credentials.invalidate(err)
+	return err
+}
+
+// PoolStat returns the statistics of the connection pool.
+func (c *Client) PoolStat() *pgxpool.Stat {
+	return c.pool.Stat()
 }
 
-// Select executes a statement that fetches rows in a transaction.
+// Close stops connecting, if lazily, and closes all connections in the pool.
+func (c *Client) Close() {
+	if c.cancel != nil {
+		c.cancel()
+		<-c.done
+	}
+	c.pool.Close()
+}
+
+// Select executes a statement that fetches rows in a read-only transaction, see ReadTx.
 func (c *Client) Select(ctx context.Context, dest interface{}, sql string, args ...interface{}) error {
-	rows, err := c.pool.Query(ctx, sql, args...)
-	if err != nil {
+	if err := c.checkConnected(); err != nil {
 		return err
 	}
-	return pgxscan.ScanAll(dest, rows)
+	return c.selectRows(ctx, dest, sql, args...)
+}
+
+func (c *Client) selectRows(ctx context.Context, dest interface{}, sql string, args ...interface{}) error {
+	return c.readTx(ctx, func(tx pgx.Tx) error {
+		return pgxscan.Select(ctx, tx, dest, sql, args...)
+	})
+}
+
+// SelectLong executes an expensive statement that fetches rows in a read-only transaction, bounded by
+// the deadline of the context rather than StatementTimeout and TotalTransactionTimeout, or by
+// StatementTimeout if the context has no deadline.
+// It is not retried, so is meant for statements run rarely, e.g. on a long background interval.
+func (c *Client) SelectLong(ctx context.Context, dest interface{}, sql string, args ...interface{}) error {
+	if err := c.checkConnected(); err != nil {
+		return err
+	}
+	timeout := c.opts.StatementTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if timeout = time.Until(deadline); timeout <= 0 {
+			return context.DeadlineExceeded
+		}
+	}
+	txOptions := c.txOptions
+	txOptions.AccessMode = pgx.ReadOnly
+	err := c.pool.BeginTxFunc(ctx, txOptions, func(tx pgx.Tx) error {
+		// Timeouts under a millisecond would round down to zero, which disables the timeout.
+		if _, err := tx.Exec(ctx, fmt.Sprintf("SET LOCAL statement_timeout = %d", statementTimeoutMillis(timeout))); err != nil {
+			return err
+		}
+		return pgxscan.Select(ctx, tx, dest, sql, args...)
+	})
+	c.credentials.invalidate(err)
+	return err
+}
+
+// statementTimeoutMillis returns the statement_timeout of the timeout, in milliseconds, which is
+// at least 1 unless the timeout is zero, disabling it.
+func statementTimeoutMillis(timeout time.Duration) int64 {
+	if timeout > 0 && timeout < time.Millisecond {
+		return 1
+	}
+	return timeout.Milliseconds()
 }
@@ -5,6 +5,8 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+
+	"github.com/jackc/pgconn"
 )
 
 // DSN constructs a postgres-compatible connection URI.
@@ -20,23 +22,108 @@ func DSN(opts Opts) string {
 	return addParametersToDSN(dsn, opts, false)
 }
 
-func configureAuthParams(opts Opts) Opts {
-	if opts.AuthMechanism == "" {
-		opts.AuthMechanism = opts.Password
+// OptsFromDSN parses a postgres connection string, either URI or key/value, into
+// Opts. Settings not carried by the connection string are inherited from defaults, except
+// for credentials: password files, secrets, credential commands and client certificates
+// are those of the connection string alone, so that they are never sent to its host.
+func OptsFromDSN(dsn string, defaults Opts) (Opts, error) {
+	config, err := pgconn.ParseConfig(dsn)
+	if err != nil {
+		return Opts{}, fmt.Errorf("parsing dsn: %w", err)
+	}
+	opts := defaults
+	opts.Name = ""
+	opts.Host = config.Host
+	opts.Port = int(config.Port)
+	opts.User = config.User
+	opts.Password = config.Password
+	opts.Database = config.Database
+	opts.PasswordFile = ""
+	opts.SecretsDir = ""
+	opts.PassFile = ""
+	opts.CredentialCommand = ""
+	opts.CredentialProvider = nil
+	opts.SSLCert = ""
+	opts.SSLKey = ""
+	// TLS settings are only carried by the connection string if given.
+	for key, field := range map[string]*string{
+		"sslmode":     &opts.SSLMode,
+		"sslrootcert": &opts.SSLRootCert,
+		"sslcert":     &opts.SSLCert,
+		"sslkey":      &opts.SSLKey,
+	} {
+		if value, ok := dsnParam(dsn, key); ok {
+			*field = value
+		}
+	}
+	opts.AuthMechanism = AuthMechanismPassword
+	if opts.SSLCert != "" && opts.SSLKey != "" {
+		opts.AuthMechanism = AuthMechanismClientCertificates
+	}
+	if appName, ok := config.RuntimeParams["application_name"]; ok {
+		opts.ApplicationName = appName
+	}
+	return opts, nil
+}
+
+// dsnParam returns the value of a parameter of a connection string, either URI or key/value.
+// Quoted key/value parameters are not supported, which TLS file paths rarely need.
+func dsnParam(dsn, key string) (string, bool) {
+	if strings.Contains(dsn, "://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", false
+		}
+		values, ok := u.Query()[key]
+		if !ok || len(values) == 0 {
+			return "", false
+		}
+		return values[0], true
 	}
+	for _, field := range strings.Fields(dsn) {
+		if parts := strings.SplitN(field, "=", 2); len(parts) == 2 && parts[0] == key {
+			return parts[1], true
+		}
+	}
+	return "", false
+}
+
+// Auth mechanisms.
+const (
+	AuthMechanismPassword           = "password"
+	AuthMechanismClientCertificates = "client_certificates"
+)
+
+func configureAuthParams(opts Opts) Opts {
 	switch opts.AuthMechanism {
-	case opts.Password:
-		// If password is not provided, grab from secrets dir.
+	case AuthMechanismClientCertificates:
+		// The client certificate authenticates the user instead.
+		opts.Password = ""
 	default:
+		// The Credentials are fetched on connecting from the CredentialProvider, see credentialProvider.
 	}
 	return opts
+}
 
+// checkAuthParams checks that the opts carry what their auth mechanism needs.
+func checkAuthParams(opts Opts) error {
+	if opts.AuthMechanism == AuthMechanismClientCertificates {
+		if opts.SSLCert == "" || opts.SSLKey == "" {
+			return fmt.Errorf("%s auth mechanism requires ssl_cert and ssl_key", opts.AuthMechanism)
+		}
+		if opts.SSLMode == "disable" {
+			return fmt.Errorf("%s auth mechanism requires TLS", opts.AuthMechanism)
+		}
+	}
+	return nil
 }
 
 func addParametersToDSN(dsn string, opts Opts, postgresCompatible bool) string {
 	var params parameters
-	// params = append(params, "sslmode", opts.ConnectionSSLMode())
-	// TODO: SSL STUFF
+	params = appendParam(params, "sslmode", opts.SSLMode)
+	params = appendParam(params, "sslrootcert", opts.SSLRootCert)
+	params = appendParam(params, "sslcert", opts.SSLCert)
+	params = appendParam(params, "sslkey", opts.SSLKey)
 	params = appendParam(params, "application_name", opts.ApplicationName)
 	if !postgresCompatible {
 		params = appendParam(params, "pool_max_conns", strconv.Itoa(opts.PoolMaxConns))
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"syscall"
+
+	"github.com/jackc/pgconn"
+)
+
+// Error classes reported by ClassifyError.
+const (
+	ErrorClassTimeout           = "timeout"
+	ErrorClassPermissionDenied  = "permission_denied"
+	ErrorClassUndefinedTable    = "undefined_table"
+	ErrorClassUndefinedColumn   = "undefined_column"
+	ErrorClassConnectionRefused = "connection_refused"
+	ErrorClassAuthentication    = "authentication_failed"
+	ErrorClassNotConnected      = "not_connected"
+	ErrorClassOther             = "other"
+)
+
+// PostgreSQL error codes, see https://www.postgresql.org/docs/current/errcodes-appendix.html.
+const (
+	codeQueryCanceled         = "57014"
+	codeLockNotAvailable      = "55P03"
+	codeInsufficientPrivilege = "42501"
+	codeUndefinedTable        = "42P01"
+	codeUndefinedColumn       = "42703"
+	codeInvalidPassword       = "28P01"
+	codeInvalidAuthorization  = "28000"
+)
+
+// ClassifyError classifies an error returned by the client, e.g. for use as a metric label.
+func ClassifyError(err error) string {
+	var pgErr *pgconn.PgError
+	switch {
+	case errors.Is(err, ErrNotConnected):
+		return ErrorClassNotConnected
+	case errors.As(err, &pgErr):
+		switch pgErr.Code {
+		case codeQueryCanceled, codeLockNotAvailable:
+			return ErrorClassTimeout
+		case codeInsufficientPrivilege:
+			return ErrorClassPermissionDenied
+		case codeUndefinedTable:
+			return ErrorClassUndefinedTable
+		case codeUndefinedColumn:
+			return ErrorClassUndefinedColumn
+		case codeInvalidPassword, codeInvalidAuthorization:
+			return ErrorClassAuthentication
+		}
+	case errors.Is(err, context.DeadlineExceeded), pgconn.Timeout(err):
+		return ErrorClassTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorClassConnectionRefused
+	}
+	return ErrorClassOther
+}
@@ -4,6 +4,18 @@ import (
 	"github.com/jackc/pgtype"
 )
 
+// ServerVersion contains the version of the server.
+type ServerVersion struct {
+	ServerVersion    string `db:"server_version"`
+	ServerVersionNum int    `db:"server_version_num"`
+}
+
+// PgExtension contains information on an installed extension.
+type PgExtension struct {
+	ExtName    string `db:"extname"`
+	ExtVersion string `db:"extversion"`
+}
+
 // PgLock contains information on locks held.
 type PgLock struct {
 	Database string `db:"database"`
@@ -12,6 +24,45 @@ type PgLock struct {
 	Count    int    `db:"count"`
 }
 
+// PgLockWait contains information on backends waiting for locks, by lock type and relation.
+type PgLockWait struct {
+	Database       string   `db:"database"`
+	DatName        string   `db:"datname"`
+	LockType       string   `db:"locktype"`
+	SchemaName     string   `db:"schemaname"`
+	RelName        string   `db:"relname"`
+	Waiting        int      `db:"waiting"`
+	MaxWaitSeconds *float64 `db:"max_wait_seconds"`
+	RootBlockers   int      `db:"root_blockers"`
+	MaxChainDepth  int      `db:"max_chain_depth"`
+}
+
+// PgLockBlockingRoot contains information on a backend at the root of a blocking chain.
+type PgLockBlockingRoot struct {
+	Database         string `db:"database"`
+	Pid              int    `db:"pid"`
+	DatName          string `db:"datname"`
+	ApplicationName  string `db:"application_name"`
+	UseName          string `db:"usename"`
+	State            string `db:"state"`
+	QueryFingerprint string `db:"query_fingerprint"`
+}
+
+// PgReplicationSlot contains information on replication slots.
+type PgReplicationSlot struct {
+	Database               string   `db:"database"`
+	SlotName               string   `db:"slot_name"`
+	SlotType               string   `db:"slot_type"`
+	Plugin                 string   `db:"plugin"`
+	DatName                string   `db:"datname"`
+	Active                 bool     `db:"active"`
+	Temporary              bool     `db:"temporary"`
+	RetainedWalBytes       *float64 `db:"retained_wal_bytes"`
+	ConfirmedFlushLagBytes *float64 `db:"confirmed_flush_lag_bytes"` // Logical slots only.
+	WalStatus              *string  `db:"wal_status"`                // PG13+.
+	SafeWalSize            *float64 `db:"safe_wal_size"`             // PG13+, with max_slot_wal_keep_size.
+}
+
 // PgStatActivity contains information on tx state.
 type PgStatActivity struct {
 	Database      string  `db:"database"`
@@ -21,6 +72,128 @@ type PgStatActivity struct {
 	MaxTxDuration float64 `db:"max_tx_duration"`
 }
 
+// PgStatArchiver contains information on WAL archiving.
+type PgStatArchiver struct {
+	Database         string   `db:"database"`
+	ArchivedCount    int      `db:"archived_count"`
+	LastArchivedWal  string   `db:"last_archived_wal"`
+	LastArchivedTime *float64 `db:"last_archived_time"`
+	FailedCount      int      `db:"failed_count"`
+	LastFailedWal    string   `db:"last_failed_wal"`
+	LastFailedTime   *float64 `db:"last_failed_time"`
+}
+
+// PgStatWal contains information on WAL generation.
+type PgStatWal struct {
+	Database            string   `db:"database"`
+	WalLSNBytes         *float64 `db:"wal_lsn_bytes"`
+	WalRecords          *int     `db:"wal_records"`            // PG14+.
+	WalFpi              *int     `db:"wal_fpi"`                // PG14+.
+	WalBytes            *float64 `db:"wal_bytes"`              // PG14+.
+	WalBuffersFull      *int     `db:"wal_buffers_full"`       // PG14+.
+	WalWrite            *int     `db:"wal_write"`              // PG14+.
+	WalSync             *int     `db:"wal_sync"`               // PG14+.
+	WalWriteTimeSeconds *float64 `db:"wal_write_time_seconds"` // PG14+, with track_wal_io_timing.
+	WalSyncTimeSeconds  *float64 `db:"wal_sync_time_seconds"`  // PG14+, with track_wal_io_timing.
+}
+
+// PgStatBgWriter contains information on the background writer and checkpointer.
+type PgStatBgWriter struct {
+	Database                   string  `db:"database"`
+	CheckpointsTimed           int     `db:"checkpoints_timed"`
+	CheckpointsReq             int     `db:"checkpoints_req"`
+	CheckpointWriteTimeSeconds float64 `db:"checkpoint_write_time_seconds"`
+	CheckpointSyncTimeSeconds  float64 `db:"checkpoint_sync_time_seconds"`
+	BuffersCheckpoint          int     `db:"buffers_checkpoint"`
+	BuffersClean               int     `db:"buffers_clean"`
+	MaxWrittenClean            int     `db:"maxwritten_clean"`
+	BuffersBackend             int     `db:"buffers_backend"`
+	BuffersBackendFsync        int     `db:"buffers_backend_fsync"`
+	BuffersAlloc               int     `db:"buffers_alloc"`
+}
+
+// PgStatDatabase contains information on databases.
+type PgStatDatabase struct {
+	Database                     string   `db:"database"`
+	DatName                      string   `db:"datname"`
+	NumBackends                  int      `db:"numbackends"`
+	XactCommit                   int      `db:"xact_commit"`
+	XactRollback                 int      `db:"xact_rollback"`
+	BlksRead                     int      `db:"blks_read"`
+	BlksHit                      int      `db:"blks_hit"`
+	TupReturned                  int      `db:"tup_returned"`
+	TupFetched                   int      `db:"tup_fetched"`
+	TupInserted                  int      `db:"tup_inserted"`
+	TupUpdated                   int      `db:"tup_updated"`
+	TupDeleted                   int      `db:"tup_deleted"`
+	Conflicts                    int      `db:"conflicts"`
+	TempFiles                    int      `db:"temp_files"`
+	TempBytes                    int      `db:"temp_bytes"`
+	Deadlocks                    int      `db:"deadlocks"`
+	ChecksumFailures             *int     `db:"checksum_failures"` // PG12+, with data checksums enabled.
+	BlkReadTimeSeconds           float64  `db:"blk_read_time_seconds"`
+	BlkWriteTimeSeconds          float64  `db:"blk_write_time_seconds"`
+	SessionTimeSeconds           *float64 `db:"session_time_seconds"`             // PG14+.
+	ActiveTimeSeconds            *float64 `db:"active_time_seconds"`              // PG14+.
+	IdleInTransactionTimeSeconds *float64 `db:"idle_in_transaction_time_seconds"` // PG14+.
+	Sessions                     *int     `db:"sessions"`                         // PG14+.
+	SessionsAbandoned            *int     `db:"sessions_abandoned"`               // PG14+.
+	SessionsFatal                *int     `db:"sessions_fatal"`                   // PG14+.
+	SessionsKilled               *int     `db:"sessions_killed"`                  // PG14+.
+	StatsReset                   *float64 `db:"stats_reset"`
+}
+
+// PgRecovery contains information on recovery, i.e. whether the server is a standby.
+type PgRecovery struct {
+	Database              string   `db:"database"`
+	IsInRecovery          bool     `db:"is_in_recovery"`
+	ReceiveReplayLagBytes *float64 `db:"receive_replay_lag_bytes"` // Standbys streaming WAL only.
+	LastReplayTimestamp   *float64 `db:"last_replay_timestamp"`    // Standbys only.
+	ReplayDelaySeconds    *float64 `db:"replay_delay_seconds"`     // Standbys only.
+}
+
+// PgStatProgress contains information on the progress of a vacuum, analyze, index build or cluster.
+type PgStatProgress struct {
+	Database       string   `db:"database"`
+	Progress       string   `db:"progress"` // The pg_stat_progress_* view, e.g. create_index.
+	Command        string   `db:"command"`
+	Pid            int      `db:"pid"`
+	DatName        string   `db:"datname"`
+	SchemaName     string   `db:"schemaname"`
+	RelName        string   `db:"relname"`
+	Phase          string   `db:"phase"`
+	BlocksTotal    *int     `db:"blocks_total"`
+	BlocksDone     *int     `db:"blocks_done"`
+	TuplesTotal    *int     `db:"tuples_total"`
+	TuplesDone     *int     `db:"tuples_done"`
+	ElapsedSeconds *float64 `db:"elapsed_seconds"`
+	AutoVacuum     bool     `db:"autovacuum"`
+}
+
+// PgStatReplication contains information on the standbys of a primary.
+type PgStatReplication struct {
+	Database         string   `db:"database"`
+	ApplicationName  string   `db:"application_name"`
+	ClientAddr       string   `db:"client_addr"`
+	State            string   `db:"state"`
+	SyncState        string   `db:"sync_state"`
+	SentLagBytes     *float64 `db:"sent_lag_bytes"`
+	WriteLagBytes    *float64 `db:"write_lag_bytes"`
+	FlushLagBytes    *float64 `db:"flush_lag_bytes"`
+	ReplayLagBytes   *float64 `db:"replay_lag_bytes"`
+	WriteLagSeconds  *float64 `db:"write_lag_seconds"`
+	FlushLagSeconds  *float64 `db:"flush_lag_seconds"`
+	ReplayLagSeconds *float64 `db:"replay_lag_seconds"`
+}
+
+// PgStatWalReceiver contains information on the WAL receiver of a standby.
+type PgStatWalReceiver struct {
+	Database   string `db:"database"`
+	Status     string `db:"status"`
+	SenderHost string `db:"sender_host"` // PG11+.
+	SlotName   string `db:"slot_name"`
+}
+
 // PgStatUserTable contains information on user tables.
 type PgStatUserTable struct {
 	Database         string             `db:"database"`
@@ -37,6 +210,7 @@ type PgStatUserTable struct {
 	NLiveTup         int                `db:"n_live_tup"`
 	NDeadTup         int                `db:"n_dead_tup"`
 	NModSinceAnalyze int                `db:"n_mod_since_analyze"`
+	NInsSinceVacuum  *int               `db:"n_ins_since_vacuum"` // PG13+.
 	LastVacuum       pgtype.Timestamptz `db:"last_vacuum"`
 	LastAutoVacuum   pgtype.Timestamptz `db:"last_autovacuum"`
 	LastAnalyze      pgtype.Timestamptz `db:"last_analyze"`
@@ -107,6 +281,63 @@ type PgStatStatement struct {
 	LocalBlksWritten    int     `db:"local_blks_written"`
 	TempBlksRead        int     `db:"temp_blks_read"`
 	TempBlksWritten     int     `db:"temp_blks_written"`
-	BlkReadTimeSeconds  int     `db:"blk_read_time_seconds"`
-	BlkWriteTimeSeconds int     `db:"blk_write_time_seconds"`
+	BlkReadTimeSeconds  float64 `db:"blk_read_time_seconds"`
+	BlkWriteTimeSeconds float64 `db:"blk_write_time_seconds"`
+}
+
+// PgDatabaseWraparound contains information on the transaction ID wraparound of a database.
+type PgDatabaseWraparound struct {
+	Database                        string `db:"database"`
+	DatName                         string `db:"datname"`
+	XidAge                          int    `db:"xid_age"`
+	MxidAge                         int    `db:"mxid_age"`
+	AutoVacuumFreezeMaxAge          int    `db:"autovacuum_freeze_max_age"`
+	AutoVacuumMultixactFreezeMaxAge int    `db:"autovacuum_multixact_freeze_max_age"`
+}
+
+// PgRelationWraparound contains information on the transaction ID wraparound of a relation.
+type PgRelationWraparound struct {
+	Database   string `db:"database"`
+	SchemaName string `db:"schemaname"`
+	RelName    string `db:"relname"`
+	XidAge     int    `db:"xid_age"`
+	MxidAge    int    `db:"mxid_age"`
+}
+
+// PgXminHolder contains information on the oldest holder of a kind holding back the xmin horizon.
+type PgXminHolder struct {
+	Database string `db:"database"`
+	Holder   string `db:"holder"` // One of backend, prepared_transaction, replication_slot or standby.
+	ID       string `db:"id"`
+	XminAge  int    `db:"xmin_age"`
+}
+
+// PgDatabaseSize contains the size of a database.
+type PgDatabaseSize struct {
+	Database  string  `db:"database"`
+	DatName   string  `db:"datname"`
+	SizeBytes float64 `db:"size_bytes"`
+}
+
+// PgRelationSize contains the size of a table or materialized view.
+type PgRelationSize struct {
+	Database   string  `db:"database"`
+	SchemaName string  `db:"schemaname"`
+	RelName    string  `db:"relname"`
+	HeapBytes  float64 `db:"heap_bytes"`
+	IndexBytes float64 `db:"index_bytes"`
+	ToastBytes float64 `db:"toast_bytes"`
+	TotalBytes float64 `db:"total_bytes"`
+}
+
+// PgBloat contains the bloat of a table or index.
+type PgBloat struct {
+	Database     string  `db:"database"`
+	RelID        int64   `db:"relid"` // Tables only.
+	SchemaName   string  `db:"schemaname"`
+	RelName      string  `db:"relname"`
+	IndexRelName string  `db:"indexrelname"` // Indexes only.
+	RealBytes    float64 `db:"real_bytes"`
+	BloatBytes   float64 `db:"bloat_bytes"`
+	BloatRatio   float64 `db:"bloat_ratio"`
 }
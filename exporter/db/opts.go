@@ -1,40 +1,63 @@
 package db
 
 import (
+	"fmt"
 	"time"
 )
 
 // Opts specify the configuration for a postgres client.
 type Opts struct {
 	//
-	Host            string `long:"postgres_host"     env:"POSTGRES_HOST"     default:"localhost" description:"Postgres host"`
-	Port            int    `long:"postgres_port"     env:"POSTGRES_PORT"     default:"5432"     description:"Postgres port"`
-	User            string `long:"postgres_user"     env:"POSTGRES_USER"     default:"postgres" description:"Postgres username"`
-	Password        string `long:"postgres_password" env:"POSTGRES_PASSWORD" default:"postgres" description:"Postgres password"`
-	Database        string `long:"postgres_database" env:"POSTGRES_DATABASE" default:"postgres" description:"Postgres database"`
-	AuthMechanism   string `long:"auth_mechanism" env:"AUTH_MECHANISM" description:"The mechanism to use when authenticating with the DB" choice:"password" choice:"client_certificates" default:"password"`
-	ApplicationName string `long:"application_name" env:"APP_NAME" required:"true"`
+	Name            string `long:"name" env:"NAME" description:"Name identifying this database target (defaults to host:port/database)" yaml:"name" toml:"name"`
+	Host            string `long:"postgres_host"     env:"POSTGRES_HOST"     default:"localhost" description:"Postgres host" yaml:"host" toml:"host"`
+	Port            int    `long:"postgres_port"     env:"POSTGRES_PORT"     default:"5432"     description:"Postgres port" yaml:"port" toml:"port"`
+	User            string `long:"postgres_user"     env:"POSTGRES_USER"     default:"postgres" description:"Postgres username" yaml:"user" toml:"user"`
+	Password        string `long:"postgres_password" env:"POSTGRES_PASSWORD" default:"postgres" description:"Postgres password" yaml:"password" toml:"password"`
+	PasswordFile    string `long:"postgres_password_file" env:"POSTGRES_PASSWORD_FILE" description:"File holding the Postgres password, instead of the password" yaml:"password_file" toml:"password_file"`
+	PassFile        string `long:"postgres_passfile" env:"PGPASSFILE" description:"Password file of the .pgpass format to look the password up in (defaults to ~/.pgpass without a password)" yaml:"passfile" toml:"passfile"`
+	SecretsDir      string `long:"secrets_dir" env:"SECRETS_DIR" description:"Directory holding the password in a file named after the target, or else named password" yaml:"secrets_dir" toml:"secrets_dir"`
+	Database        string `long:"postgres_database" env:"POSTGRES_DATABASE" default:"postgres" description:"Postgres database" yaml:"database" toml:"database"`
+	AuthMechanism   string `long:"auth_mechanism" env:"AUTH_MECHANISM" description:"The mechanism to use when authenticating with the DB" choice:"password" choice:"client_certificates" default:"password" yaml:"auth_mechanism" toml:"auth_mechanism"`
+	ApplicationName string `long:"application_name" env:"APP_NAME" required:"true" yaml:"application_name" toml:"application_name"`
+	// Credentials of new connections, taking precedence over passwords.
+	CredentialCommand  string             `long:"credential_command" env:"CREDENTIAL_COMMAND" description:"Shell command printing the password, e.g. an auth token, or JSON of user, password and expires_at" yaml:"credential_command" toml:"credential_command"`
+	CredentialTTL      time.Duration      `long:"credential_ttl" env:"CREDENTIAL_TTL" default:"0s" description:"How long credentials printed without expires_at are used for (0 is until they fail to authenticate)" yaml:"credential_ttl" toml:"credential_ttl"`
+	CredentialProvider CredentialProvider `no-flag:"true" yaml:"-" toml:"-"`
+	// TLS parameters, of which certificate files are re-read when modified.
+	SSLMode       string `long:"ssl_mode" env:"SSL_MODE" default:"prefer" description:"How to negotiate TLS with the database, as libpq's sslmode" choice:"disable" choice:"allow" choice:"prefer" choice:"require" choice:"verify-ca" choice:"verify-full" yaml:"ssl_mode" toml:"ssl_mode"`
+	SSLRootCert   string `long:"ssl_root_cert" env:"SSL_ROOT_CERT" description:"Path to the CA certificates to verify the server against" yaml:"ssl_root_cert" toml:"ssl_root_cert"`
+	SSLCert       string `long:"ssl_cert" env:"SSL_CERT" description:"Path to the client certificate, required by the client_certificates auth mechanism" yaml:"ssl_cert" toml:"ssl_cert"`
+	SSLKey        string `long:"ssl_key" env:"SSL_KEY" description:"Path to the key of the client certificate" yaml:"ssl_key" toml:"ssl_key"`
+	SSLServerName string `long:"ssl_server_name" env:"SSL_SERVER_NAME" description:"Server name to verify the server certificate against and send via SNI (defaults to the host)" yaml:"ssl_server_name" toml:"ssl_server_name"`
 	// Connection parameters.
-	ConnectTimeout       time.Duration `long:"connect_timeout" env:"CONNECT_TIMEOUT" default:"10s" description:"Postgres connection timeout"`
-	MaxConnectionRetries int           `long:"max_retries" env:"MAX_RETRIES" default:"6" description:"Max number of retry attempts when forming a connection to the database before giving up. (0 is no retries, -1 is infinite retries or, if possible, until the context times out)."`
+	ConnectTimeout       time.Duration `long:"connect_timeout" env:"CONNECT_TIMEOUT" default:"10s" description:"Postgres connection timeout" yaml:"connect_timeout" toml:"connect_timeout"`
+	MaxConnectionRetries int           `long:"max_retries" env:"MAX_RETRIES" default:"6" description:"Max number of retry attempts of db.New connecting to the database before giving up. (0 is no retries, -1 is infinite retries or, if possible, until the context times out). Targets of the exporter are connected to lazily, retrying until connected, and probes are not retried." yaml:"max_retries" toml:"max_retries"`
 	// Client connection optional parameters.
-	DefaultIsolationLevel           string        `long:"default_isolation_level" env:"DEFAULT_ISOLATION_LEVEL" default:"REPEATABLE_READ" description:"default isolation level for DB transactions" choice:"READ_COMMITTED" choice:"REPEATABLE_READ" choice:"SERIALIZABLE"`
-	StatementTimeout                time.Duration `long:"statement_timeout" env:"STATEMENT_TIMEOUT" default:"5s" description:"Abort any statement that takes more than the specified number of milliseconds, starting from the time the command arrives at the server from the client. A value of zero (the default) turns this off."`
-	LockTimeout                     time.Duration `long:"lock_timeout" env:"LOCK_TIMEOUT" default:"0ms" description:"Abort any statement that waits longer than the specified number of milliseconds while attempting to acquire a lock on a table, index, row, or other database object. The time limit applies separately to each lock acquisition attempt. The limit applies both to explicit locking requests (such as LOCK TABLE, or SELECT FOR UPDATE without NOWAIT) and to implicitly-acquired locks. A value of zero (the default) turns this off."`
-	IdleInTransactionSessionTimeout time.Duration `long:"idle_in_transaction_session_timeout" env:"IDLE_IN_TRANSACTION_SESSION_TIMEOUT" default:"5s" description:"Terminate any session with an open transaction that has been idle for longer than the specified duration in milliseconds. This allows any locks held by that session to be released and the connection slot to be reused; it also allows tuples visible only to this transaction to be vacuumed. A value of zero (the default) turns this off."`
+	DefaultIsolationLevel           string        `long:"default_isolation_level" env:"DEFAULT_ISOLATION_LEVEL" default:"REPEATABLE_READ" description:"default isolation level for DB transactions" choice:"READ_COMMITTED" choice:"REPEATABLE_READ" choice:"SERIALIZABLE" yaml:"default_isolation_level" toml:"default_isolation_level"`
+	StatementTimeout                time.Duration `long:"statement_timeout" env:"STATEMENT_TIMEOUT" default:"5s" description:"Abort any statement that takes more than the specified number of milliseconds, starting from the time the command arrives at the server from the client. A value of zero (the default) turns this off." yaml:"statement_timeout" toml:"statement_timeout"`
+	LockTimeout                     time.Duration `long:"lock_timeout" env:"LOCK_TIMEOUT" default:"0ms" description:"Abort any statement that waits longer than the specified number of milliseconds while attempting to acquire a lock on a table, index, row, or other database object. The time limit applies separately to each lock acquisition attempt. The limit applies both to explicit locking requests (such as LOCK TABLE, or SELECT FOR UPDATE without NOWAIT) and to implicitly-acquired locks. A value of zero (the default) turns this off." yaml:"lock_timeout" toml:"lock_timeout"`
+	IdleInTransactionSessionTimeout time.Duration `long:"idle_in_transaction_session_timeout" env:"IDLE_IN_TRANSACTION_SESSION_TIMEOUT" default:"5s" description:"Terminate any session with an open transaction that has been idle for longer than the specified duration in milliseconds. This allows any locks held by that session to be released and the connection slot to be reused; it also allows tuples visible only to this transaction to be vacuumed. A value of zero (the default) turns this off." yaml:"idle_in_transaction_session_timeout" toml:"idle_in_transaction_session_timeout"`
 	// Transaction options.
-	TotalTransactionTimeout      time.Duration `long:"total_transaction_timeout" env:"TOTAL_TRANSACTION_TIMEOUT" default:"5s" description:"The total time spent waiting for a transaction to finish, including retries, before cancelling it client side."`
-	InitialTransactionRetryDelay time.Duration `long:"initial_transaction_retry_delay" env:"INITIAL_TRANSACTION_RETRY_DELAY" default:"50ms" description:"The initial duration of time to wait before retrying a transaction attempt"`
-	BaseTransactionRetryDelay    time.Duration `long:"base_transaction_retry_delay" env:"BASE_TRANSACTION_RETRY_DELAY" default:"50ms" description:"The duration of time to wait before retrying a transaction attempt"`
-	MaxTransactionAttempts       int           `long:"max_transaction_attempts" env:"MAX_TRANSACTION_ATTEMPTS" default:"-1" description:"The maximum number of attempts at executing a transaction (-1 is infinite or until the context expires)."`
-	ReadOnly                     bool          `long:"read_only" env:"READ_ONLY" description:"Determines whether transactions are read-only and can be routed to read-replicas."`
+	TotalTransactionTimeout      time.Duration `long:"total_transaction_timeout" env:"TOTAL_TRANSACTION_TIMEOUT" default:"5s" description:"The total time spent waiting for a transaction to finish, including retries, before cancelling it client side." yaml:"total_transaction_timeout" toml:"total_transaction_timeout"`
+	InitialTransactionRetryDelay time.Duration `long:"initial_transaction_retry_delay" env:"INITIAL_TRANSACTION_RETRY_DELAY" default:"50ms" description:"The initial duration of time to wait before retrying a transaction attempt" yaml:"initial_transaction_retry_delay" toml:"initial_transaction_retry_delay"`
+	BaseTransactionRetryDelay    time.Duration `long:"base_transaction_retry_delay" env:"BASE_TRANSACTION_RETRY_DELAY" default:"50ms" description:"The duration of time to wait before retrying a transaction attempt" yaml:"base_transaction_retry_delay" toml:"base_transaction_retry_delay"`
+	MaxTransactionAttempts       int           `long:"max_transaction_attempts" env:"MAX_TRANSACTION_ATTEMPTS" default:"-1" description:"The maximum number of attempts at executing a transaction (-1 is infinite or until the context expires)." yaml:"max_transaction_attempts" toml:"max_transaction_attempts"`
+	ReadOnly                     bool          `long:"read_only" env:"READ_ONLY" description:"Determines whether transactions are read-only and can be routed to read-replicas." yaml:"read_only" toml:"read_only"`
 	// pgxpool.ConnConfig
-	PoolMaxConns          int           `long:"pool_max_conns" env:"MAX_CONNS" default:"10" description:"Max open connections to the database"`
-	PoolMinConns          int           `long:"pool_min_conns" env:"MIN_CONNS" default:"2" description:"Min open connections to the database"`
-	PoolMaxConnLifetime   time.Duration `long:"pool_max_conn_lifetime" env:"MAX_CONN_LIFETIME" default:"1h" description:"Max connection lifetime, after which, connections will be lazily closed"`
-	PoolMaxConnIdleTime   time.Duration `long:"pool_max_conn_idle_time" env:"MAX_CONN_IDLE_TIME" default:"30m" description:"Max connection idle time, after which, connections will be lazily closed"`
-	PoolHealthCheckPeriod time.Duration `long:"pool_health_check_period" env:"HEALTH_CHECK_PERIOD" default:"1m" description:"Health check period is the duration between checks of the health of idle connections"`
+	PoolMaxConns          int           `long:"pool_max_conns" env:"MAX_CONNS" default:"10" description:"Max open connections to the database" yaml:"pool_max_conns" toml:"pool_max_conns"`
+	PoolMinConns          int           `long:"pool_min_conns" env:"MIN_CONNS" default:"2" description:"Min open connections to the database" yaml:"pool_min_conns" toml:"pool_min_conns"`
+	PoolMaxConnLifetime   time.Duration `long:"pool_max_conn_lifetime" env:"MAX_CONN_LIFETIME" default:"1h" description:"Max connection lifetime, after which, connections will be lazily closed" yaml:"pool_max_conn_lifetime" toml:"pool_max_conn_lifetime"`
+	PoolMaxConnIdleTime   time.Duration `long:"pool_max_conn_idle_time" env:"MAX_CONN_IDLE_TIME" default:"30m" description:"Max connection idle time, after which, connections will be lazily closed" yaml:"pool_max_conn_idle_time" toml:"pool_max_conn_idle_time"`
+	PoolHealthCheckPeriod time.Duration `long:"pool_health_check_period" env:"HEALTH_CHECK_PERIOD" default:"1m" description:"Health check period is the duration between checks of the health of idle connections" yaml:"pool_health_check_period" toml:"pool_health_check_period"`
 	// pgx.ConnConfig
-	StatementCacheCapacity int    `long:"statement_cache_capacity" env:"STATEMENT_CACHE_CAPACITY" default:"512" description:"The maximum number of prepared statements in the automatic statement cache. Set to 0 disable automatic statement caching"`
-	StatementCacheMode     string `long:"statement_cache_mode" env:"STATEMENT_CACHE_MODE" default:"prepare" description:"Prepare will create prepared statements on the PostgreSQL server. Describe will use the anonymous prepared statement to describe a statement without creating a statement on the server. Describe is primarily useful when the environment does not allow prepared statements such as when running a connection poller like PgBouncer or DeadPool" choice:"prepare" choice:"describe"`
+	StatementCacheCapacity int    `long:"statement_cache_capacity" env:"STATEMENT_CACHE_CAPACITY" default:"512" description:"The maximum number of prepared statements in the automatic statement cache. Set to 0 disable automatic statement caching" yaml:"statement_cache_capacity" toml:"statement_cache_capacity"`
+	StatementCacheMode     string `long:"statement_cache_mode" env:"STATEMENT_CACHE_MODE" default:"prepare" description:"Prepare will create prepared statements on the PostgreSQL server. Describe will use the anonymous prepared statement to describe a statement without creating a statement on the server. Describe is primarily useful when the environment does not allow prepared statements such as when running a connection poller like PgBouncer or DeadPool" choice:"prepare" choice:"describe" yaml:"statement_cache_mode" toml:"statement_cache_mode"`
+}
+
+// TargetName returns the name identifying the target, defaulting to host:port/database.
+func (o Opts) TargetName() string {
+	if o.Name != "" {
+		return o.Name
+	}
+	return fmt.Sprintf("%s:%d/%s", o.Host, o.Port, o.Database)
 }
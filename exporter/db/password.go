@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgpassfile"
+)
+
+// passwordProvider provides the password of a target from the first of the password file,
+// the secrets directory and the passfile that are configured, falling back to the password.
+type passwordProvider struct {
+	opts Opts
+}
+
+// Credentials implements the CredentialProvider.
+func (p passwordProvider) Credentials(context.Context) (Credentials, error) {
+	password, err := p.resolve()
+	if err != nil {
+		return Credentials{}, err
+	}
+	return Credentials{Password: password}, nil
+}
+
+// resolve reads the password, consulting ~/.pgpass only in the absence of a password.
+func (p passwordProvider) resolve() (string, error) {
+	if p.opts.PasswordFile != "" {
+		return readPasswordFile(p.opts.PasswordFile)
+	}
+	if p.opts.SecretsDir != "" {
+		return p.readSecretsDir()
+	}
+	passFile := p.opts.PassFile
+	if passFile == "" && p.opts.Password == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			passFile = filepath.Join(home, ".pgpass")
+		}
+	}
+	if passFile != "" {
+		password, err := p.readPassFile(passFile)
+		if err != nil {
+			return "", err
+		}
+		if password != "" {
+			return password, nil
+		}
+	}
+	return p.opts.Password, nil
+}
+
+// readSecretsDir reads the password from the file of the secrets directory named after the
+// target, or else from its password file, as mounted from a Kubernetes secret.
+func (p passwordProvider) readSecretsDir() (string, error) {
+	var names []string
+	if p.opts.Name != "" {
+		names = append(names, p.opts.Name)
+	}
+	for _, name := range append(names, "password") {
+		password, err := readPasswordFile(filepath.Join(p.opts.SecretsDir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		return password, err
+	}
+	return "", fmt.Errorf("no password for %s in %s", p.opts.TargetName(), p.opts.SecretsDir)
+}
+
+// readPassFile looks the target up in a passfile of the .pgpass format, returning an empty
+// password if it is not found. A missing ~/.pgpass is not an error.
+func (p passwordProvider) readPassFile(path string) (string, error) {
+	passFile, err := pgpassfile.ReadPassfile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && p.opts.PassFile == "" {
+			return "", nil
+		}
+		return "", fmt.Errorf("reading passfile: %w", err)
+	}
+	return passFile.FindPassword(p.opts.Host, strconv.Itoa(p.opts.Port), p.opts.Database, p.opts.User), nil
+}
+
+// readPasswordFile reads a password from a file, ignoring trailing newlines.
+func readPasswordFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading password: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
@@ -0,0 +1,218 @@
+package db
+
+import (
+	"context"
+
+	"github.com/odonate/postgres-exporter/exporter/db/model"
+)
+
+// sqlSelectPgTableBloat estimates the bloat of tables from their statistics, comparing the pages
+// they use to the pages their live rows of average width would fill, given their fillfactor.
+// Tables with columns lacking statistics, or of the name type, cannot be estimated.
+const sqlSelectPgTableBloat = `
+SELECT
+    current_database() as database,
+    tblid::bigint as relid,
+    schemaname,
+    tblname as relname,
+    (bs * tblpages)::float as real_bytes,
+    (CASE WHEN tblpages - est_tblpages_ff > 0 THEN (tblpages - est_tblpages_ff) * bs ELSE 0 END)::float as bloat_bytes,
+    (CASE WHEN tblpages > 0 AND tblpages - est_tblpages_ff > 0 THEN (tblpages - est_tblpages_ff) / tblpages::float ELSE 0 END)::float as bloat_ratio
+FROM (
+    SELECT
+        ceil(reltuples / ((bs - page_hdr) * fillfactor / (tpl_size * 100))) + ceil(toasttuples / 4) as est_tblpages_ff,
+        tblpages, bs, tblid, schemaname, tblname, is_na
+    FROM (
+        SELECT
+            (4 + tpl_hdr_size + tpl_data_size + (2 * ma)
+                - CASE WHEN tpl_hdr_size % ma = 0 THEN ma ELSE tpl_hdr_size % ma END
+                - CASE WHEN ceil(tpl_data_size)::int % ma = 0 THEN ma ELSE ceil(tpl_data_size)::int % ma END
+            ) as tpl_size,
+            (heappages + toastpages) as tblpages,
+            reltuples, toasttuples, bs, page_hdr, tblid, schemaname, tblname, fillfactor, is_na
+        FROM (
+            SELECT
+                tbl.oid as tblid,
+                ns.nspname as schemaname,
+                tbl.relname as tblname,
+                tbl.reltuples,
+                tbl.relpages as heappages,
+                COALESCE(toast.relpages, 0) as toastpages,
+                COALESCE(toast.reltuples, 0) as toasttuples,
+                COALESCE(substring(array_to_string(tbl.reloptions, ' ') FROM 'fillfactor=([0-9]+)')::smallint, 100) as fillfactor,
+                current_setting('block_size')::numeric as bs,
+                CASE WHEN version() ~ 'mingw32' OR version() ~ '64-bit|x86_64|ppc64|ia64|amd64' THEN 8 ELSE 4 END as ma,
+                24 as page_hdr,
+                23 + CASE WHEN max(COALESCE(s.null_frac, 0)) > 0 THEN (7 + count(s.attname)) / 8 ELSE 0::int END
+                    + CASE WHEN bool_or(att.attname = 'oid' AND att.attnum < 0) THEN 4 ELSE 0 END as tpl_hdr_size,
+                sum((1 - COALESCE(s.null_frac, 0)) * COALESCE(s.avg_width, 0)) as tpl_data_size,
+                bool_or(att.atttypid = 'pg_catalog.name'::regtype)
+                    OR sum(CASE WHEN att.attnum > 0 THEN 1 ELSE 0 END) <> count(s.attname) as is_na
+            FROM pg_attribute att
+            JOIN pg_class tbl ON att.attrelid = tbl.oid
+            JOIN pg_namespace ns ON ns.oid = tbl.relnamespace
+            LEFT JOIN pg_stats s ON s.schemaname = ns.nspname
+                AND s.tablename = tbl.relname AND s.inherited = false AND s.attname = att.attname
+            LEFT JOIN pg_class toast ON tbl.reltoastrelid = toast.oid
+            WHERE NOT att.attisdropped
+                AND tbl.relkind IN ('r', 'm')
+                AND tbl.reltuples >= 0
+            GROUP BY 1, 2, 3, 4, 5, 6, 7, 8, 9, 10
+        ) s
+    ) s2
+) s3
+WHERE NOT is_na
+ORDER BY bloat_bytes DESC
+LIMIT $1`
+
+// sqlSelectPgIndexBloat estimates the bloat of btree indexes from the statistics of their columns,
+// comparing the pages they use to the pages their entries would fill, given their fillfactor.
+const sqlSelectPgIndexBloat = `
+SELECT
+    current_database() as database,
+    nspname as schemaname,
+    tblname as relname,
+    idxname as indexrelname,
+    (bs * relpages)::float as real_bytes,
+    (CASE WHEN relpages > est_pages_ff THEN bs * (relpages - est_pages_ff) ELSE 0 END)::float as bloat_bytes,
+    (CASE WHEN relpages > est_pages_ff THEN (relpages - est_pages_ff)::float / relpages ELSE 0 END)::float as bloat_ratio
+FROM (
+    SELECT
+        COALESCE(1 + ceil(reltuples / floor((bs - pageopqdata - pagehdr) * fillfactor / (100 * (4 + nulldatahdrwidth)::float))), 0) as est_pages_ff,
+        bs, nspname, tblname, idxname, relpages, is_na
+    FROM (
+        SELECT
+            bs, nspname, tblname, idxname, reltuples, relpages, fillfactor,
+            (index_tuple_hdr_bm
+                + maxalign - CASE WHEN index_tuple_hdr_bm % maxalign = 0 THEN maxalign ELSE index_tuple_hdr_bm % maxalign END
+                + nulldatawidth + maxalign - CASE
+                    WHEN nulldatawidth = 0 THEN 0
+                    WHEN nulldatawidth::integer % maxalign = 0 THEN maxalign
+                    ELSE nulldatawidth::integer % maxalign
+                END
+            )::numeric as nulldatahdrwidth,
+            pagehdr, pageopqdata, is_na
+        FROM (
+            SELECT
+                n.nspname,
+                i.tblname,
+                i.idxname,
+                i.reltuples,
+                i.relpages,
+                i.idxoid,
+                i.fillfactor,
+                current_setting('block_size')::numeric as bs,
+                CASE WHEN version() ~ 'mingw32' OR version() ~ '64-bit|x86_64|ppc64|ia64|amd64' THEN 8 ELSE 4 END as maxalign,
+                24 as pagehdr,
+                16 as pageopqdata,
+                CASE WHEN max(COALESCE(s.null_frac, 0)) = 0 THEN 8 ELSE 8 + ((32 + 8 - 1) / 8) END as index_tuple_hdr_bm,
+                sum((1 - COALESCE(s.null_frac, 0)) * COALESCE(s.avg_width, 1024)) as nulldatawidth,
+                max(CASE WHEN i.atttypid = 'pg_catalog.name'::regtype THEN 1 ELSE 0 END) > 0 as is_na
+            FROM (
+                SELECT
+                    ct.relname as tblname,
+                    ct.relnamespace,
+                    ic.idxname,
+                    ic.attpos,
+                    ic.reltuples,
+                    ic.relpages,
+                    ic.tbloid,
+                    ic.idxoid,
+                    ic.fillfactor,
+                    COALESCE(a1.attnum, a2.attnum) as attnum,
+                    COALESCE(a1.attname, a2.attname) as attname,
+                    COALESCE(a1.atttypid, a2.atttypid) as atttypid,
+                    CASE WHEN a1.attnum IS NULL THEN ic.idxname ELSE ct.relname END as attrelname
+                FROM (
+                    SELECT
+                        idxname, reltuples, relpages, tbloid, idxoid, fillfactor, indkey,
+                        generate_series(1, indnatts) as attpos
+                    FROM (
+                        SELECT
+                            ci.relname as idxname,
+                            ci.reltuples,
+                            ci.relpages,
+                            i.indrelid as tbloid,
+                            i.indexrelid as idxoid,
+                            COALESCE(substring(array_to_string(ci.reloptions, ' ') FROM 'fillfactor=([0-9]+)')::smallint, 90) as fillfactor,
+                            i.indnatts,
+                            string_to_array(textin(int2vectorout(i.indkey)), ' ')::int[] as indkey
+                        FROM pg_index i
+                        JOIN pg_class ci ON ci.oid = i.indexrelid
+                        WHERE ci.relam = (SELECT oid FROM pg_am WHERE amname = 'btree')
+                            AND ci.relpages > 0
+                    ) idx_data
+                ) ic
+                JOIN pg_class ct ON ct.oid = ic.tbloid
+                LEFT JOIN pg_attribute a1 ON ic.indkey[ic.attpos] <> 0
+                    AND a1.attrelid = ic.tbloid
+                    AND a1.attnum = ic.indkey[ic.attpos]
+                LEFT JOIN pg_attribute a2 ON ic.indkey[ic.attpos] = 0
+                    AND a2.attrelid = ic.idxoid
+                    AND a2.attnum = ic.attpos
+            ) i
+            JOIN pg_namespace n ON n.oid = i.relnamespace
+            JOIN pg_stats s ON s.schemaname = n.nspname
+                AND s.tablename = i.attrelname
+                AND s.attname = i.attname
+            GROUP BY 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11
+        ) rows_data_stats
+    ) rows_hdr_pdg_stats
+) relation_stats
+WHERE NOT is_na
+ORDER BY bloat_bytes DESC
+LIMIT $1`
+
+// sqlSelectPgTableBloatApprox measures the bloat of tables by scanning the pages the visibility map
+// does not show as all-visible, via the pgstattuple extension.
+const sqlSelectPgTableBloatApprox = `
+SELECT
+    current_database() as database,
+    c.oid::bigint as relid,
+    n.nspname as schemaname,
+    c.relname,
+    a.table_len::float as real_bytes,
+    (a.dead_tuple_len + a.approx_free_space)::float as bloat_bytes,
+    COALESCE((a.dead_tuple_len + a.approx_free_space)::float / NULLIF(a.table_len, 0), 0)::float as bloat_ratio
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+CROSS JOIN LATERAL pgstattuple_approx(c.oid) a
+WHERE c.oid::bigint = ANY($1)`
+
+// SelectPgTableBloat selects the estimated bloat of up to limit of the most bloated tables.
+// Estimates are expensive, so are bounded by the deadline of the context alone, see SelectLong.
+func (db *Client) SelectPgTableBloat(ctx context.Context, limit int) ([]*model.PgBloat, error) {
+	pgBloats := []*model.PgBloat{}
+	if limit <= 0 {
+		return pgBloats, nil
+	}
+	if err := db.SelectLong(ctx, &pgBloats, sqlSelectPgTableBloat, limit); err != nil {
+		return nil, err
+	}
+	return pgBloats, nil
+}
+
+// SelectPgIndexBloat selects the estimated bloat of up to limit of the most bloated btree indexes.
+func (db *Client) SelectPgIndexBloat(ctx context.Context, limit int) ([]*model.PgBloat, error) {
+	pgBloats := []*model.PgBloat{}
+	if limit <= 0 {
+		return pgBloats, nil
+	}
+	if err := db.SelectLong(ctx, &pgBloats, sqlSelectPgIndexBloat, limit); err != nil {
+		return nil, err
+	}
+	return pgBloats, nil
+}
+
+// SelectPgTableBloatApprox selects the bloat of the tables as measured by pgstattuple_approx,
+// which requires the pgstattuple extension.
+func (db *Client) SelectPgTableBloatApprox(ctx context.Context, relids []int64) ([]*model.PgBloat, error) {
+	pgBloats := []*model.PgBloat{}
+	if len(relids) == 0 {
+		return pgBloats, nil
+	}
+	if err := db.SelectLong(ctx, &pgBloats, sqlSelectPgTableBloatApprox, relids); err != nil {
+		return nil, err
+	}
+	return pgBloats, nil
+}
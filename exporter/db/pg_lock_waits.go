@@ -0,0 +1,126 @@
+package db
+
+import (
+	"context"
+
+	"github.com/odonate/postgres-exporter/exporter/db/model"
+)
+
+// waiting holds the backends waiting for a lock, which is one at a time, and the pids blocking them.
+// Before PG14, waits are timed from the start of the statement.
+const sqlPgLockWaitingHead = `
+WITH RECURSIVE waiting AS (
+    SELECT DISTINCT ON (l.pid)
+        l.pid,
+        l.locktype,
+        l.database,
+        l.relation,
+        `
+
+const sqlPgLockWaitingStart = `a.query_start`
+
+// PG14 added pg_locks.waitstart, which can briefly be null after the wait started.
+const sqlPgLockWaitingStart14 = `COALESCE(l.waitstart, a.query_start)`
+
+const sqlPgLockWaitingTail = ` as wait_start,
+        pg_blocking_pids(l.pid) as blocking_pids
+    FROM pg_locks l
+    LEFT JOIN pg_stat_activity a ON a.pid = l.pid
+    WHERE NOT l.granted
+)`
+
+// chain follows the blockers of every waiting backend up to the roots, i.e. the blockers that do not wait themselves,
+// stopping at deadlock cycles. Relations are only named in the current database, and by OID in others.
+const sqlSelectPgLockWaits = `,
+chain AS (
+    SELECT w.pid as waiter, b.pid as blocker, 1 as depth, ARRAY[w.pid] as path
+    FROM waiting w
+    CROSS JOIN LATERAL unnest(w.blocking_pids) b(pid)
+    UNION ALL
+    SELECT c.waiter, b.pid, c.depth + 1, c.path || c.blocker
+    FROM chain c
+    JOIN waiting w ON w.pid = c.blocker
+    CROSS JOIN LATERAL unnest(w.blocking_pids) b(pid)
+    WHERE NOT b.pid = ANY(c.path || c.blocker)
+),
+depths AS (
+    SELECT waiter, max(depth) as depth
+    FROM chain
+    GROUP BY waiter
+),
+roots AS (
+    SELECT DISTINCT c.waiter, c.blocker as root
+    FROM chain c
+    WHERE NOT EXISTS (SELECT 1 FROM waiting w WHERE w.pid = c.blocker)
+)
+SELECT
+    current_database() as database,
+    COALESCE(d.datname, '') as datname,
+    w.locktype,
+    COALESCE(n.nspname, '') as schemaname,
+    COALESCE(c.relname, w.relation::text, '') as relname,
+    count(DISTINCT w.pid) as waiting,
+    max(EXTRACT(EPOCH FROM now() - w.wait_start))::float as max_wait_seconds,
+    count(DISTINCT r.root) as root_blockers,
+    COALESCE(max(dp.depth), 0) as max_chain_depth
+FROM waiting w
+LEFT JOIN depths dp ON dp.waiter = w.pid
+LEFT JOIN roots r ON r.waiter = w.pid
+LEFT JOIN pg_database d ON d.oid = w.database
+LEFT JOIN pg_class c ON c.oid = w.relation AND d.datname = current_database()
+LEFT JOIN pg_namespace n ON n.oid = c.relnamespace
+GROUP BY 2, 3, 4, 5`
+
+// Roots are the blockers of waiting backends that do not wait themselves; prepared transactions have pid 0.
+const sqlSelectPgLockBlockingRoots = `,
+roots AS (
+    SELECT DISTINCT unnest(blocking_pids) as pid
+    FROM waiting
+    EXCEPT
+    SELECT pid FROM waiting
+)
+SELECT
+    current_database() as database,
+    r.pid,
+    COALESCE(a.datname, '') as datname,
+    COALESCE(a.application_name, '') as application_name,
+    COALESCE(a.usename, '') as usename,
+    COALESCE(a.state, '') as state,
+    `
+
+const sqlPgLockQueryFingerprint = `COALESCE(left(md5(a.query), 16), '')`
+
+// PG14 added pg_stat_activity.query_id, which is computed with compute_query_id.
+const sqlPgLockQueryFingerprint14 = `COALESCE(a.query_id::text, left(md5(a.query), 16), '')`
+
+const sqlSelectPgLockBlockingRootsTail = ` as query_fingerprint
+FROM roots r
+LEFT JOIN pg_stat_activity a ON a.pid = r.pid`
+
+// SelectPgLockWaits selects stats on backends waiting for locks and the chains of backends blocking them,
+// by lock type and relation.
+func (db *Client) SelectPgLockWaits(ctx context.Context) ([]*model.PgLockWait, error) {
+	pgLockWaits := []*model.PgLockWait{}
+	sql := db.sqlForVersion(
+		sqlPgLockWaitingHead+sqlPgLockWaitingStart+sqlPgLockWaitingTail+sqlSelectPgLockWaits,
+		map[int]string{140000: sqlPgLockWaitingHead + sqlPgLockWaitingStart14 + sqlPgLockWaitingTail + sqlSelectPgLockWaits},
+	)
+	if err := db.Select(ctx, &pgLockWaits, sql); err != nil {
+		return nil, err
+	}
+	return pgLockWaits, nil
+}
+
+// SelectPgLockBlockingRoots selects the backends at the root of blocking chains.
+func (db *Client) SelectPgLockBlockingRoots(ctx context.Context) ([]*model.PgLockBlockingRoot, error) {
+	pgLockBlockingRoots := []*model.PgLockBlockingRoot{}
+	waiting := sqlPgLockWaitingHead + sqlPgLockWaitingStart + sqlPgLockWaitingTail
+	sql := db.sqlForVersion(
+		waiting+sqlSelectPgLockBlockingRoots+sqlPgLockQueryFingerprint+sqlSelectPgLockBlockingRootsTail,
+		map[int]string{140000: waiting + sqlSelectPgLockBlockingRoots + sqlPgLockQueryFingerprint14 + sqlSelectPgLockBlockingRootsTail},
+	)
+	if err := db.Select(ctx, &pgLockBlockingRoots, sql); err != nil {
+		return nil, err
+	}
+	return pgLockBlockingRoots, nil
+}
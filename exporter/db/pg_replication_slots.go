@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+
+	"github.com/odonate/postgres-exporter/exporter/db/model"
+)
+
+// Slots retain WAL from their restart_lsn up to the current WAL LSN, or the replayed one on standbys.
+const sqlSelectPgReplicationSlotsColumns = `
+SELECT
+    current_database() as database,
+    slot_name,
+    slot_type,
+    COALESCE(plugin, '') as plugin,
+    COALESCE(database, '') as datname,
+    active,
+    temporary,
+    pg_wal_lsn_diff(
+        CASE WHEN pg_is_in_recovery() THEN pg_last_wal_replay_lsn() ELSE pg_current_wal_lsn() END,
+        restart_lsn
+    )::float as retained_wal_bytes,
+    pg_wal_lsn_diff(
+        CASE WHEN pg_is_in_recovery() THEN pg_last_wal_replay_lsn() ELSE pg_current_wal_lsn() END,
+        confirmed_flush_lsn
+    )::float as confirmed_flush_lag_bytes`
+
+const sqlSelectPgReplicationSlots = sqlSelectPgReplicationSlotsColumns + `
+FROM pg_replication_slots`
+
+// PG13 added wal_status and safe_wal_size, along with max_slot_wal_keep_size.
+const sqlSelectPgReplicationSlots13 = sqlSelectPgReplicationSlotsColumns + `,
+    wal_status,
+    safe_wal_size::float as safe_wal_size
+FROM pg_replication_slots`
+
+// SelectPgReplicationSlots selects stats on replication slots.
+func (db *Client) SelectPgReplicationSlots(ctx context.Context) ([]*model.PgReplicationSlot, error) {
+	pgReplicationSlots := []*model.PgReplicationSlot{}
+	sql := db.sqlForVersion(sqlSelectPgReplicationSlots, map[int]string{130000: sqlSelectPgReplicationSlots13})
+	if err := db.Select(ctx, &pgReplicationSlots, sql); err != nil {
+		return nil, err
+	}
+	return pgReplicationSlots, nil
+}
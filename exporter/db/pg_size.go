@@ -0,0 +1,65 @@
+package db
+
+import (
+	"context"
+
+	"github.com/odonate/postgres-exporter/exporter/db/model"
+)
+
+// pg_database_size requires the CONNECT privilege on the database.
+const sqlSelectPgDatabaseSize = `
+SELECT
+    current_database() as database,
+    datname,
+    pg_database_size(datname)::float as size_bytes
+FROM pg_database
+WHERE has_database_privilege(datname, 'CONNECT')`
+
+// Sizes are null for relations dropped since reading pg_class, which are skipped. The heap size includes
+// the free space and visibility maps, and the TOAST size the TOAST index.
+const sqlSelectPgRelationSize = `
+SELECT
+    current_database() as database,
+    schemaname,
+    relname,
+    COALESCE(table_bytes - toast_bytes, 0)::float as heap_bytes,
+    COALESCE(index_bytes, 0)::float as index_bytes,
+    toast_bytes::float as toast_bytes,
+    total_bytes::float as total_bytes
+FROM (
+    SELECT
+        n.nspname as schemaname,
+        c.relname,
+        pg_table_size(c.oid) as table_bytes,
+        pg_indexes_size(c.oid) as index_bytes,
+        COALESCE(pg_total_relation_size(NULLIF(c.reltoastrelid, 0)), 0) as toast_bytes,
+        pg_total_relation_size(c.oid) as total_bytes
+    FROM pg_class c
+    JOIN pg_namespace n ON n.oid = c.relnamespace
+    WHERE c.relkind IN ('r', 'm')
+) sizes
+WHERE total_bytes >= $2
+ORDER BY total_bytes DESC
+LIMIT $1`
+
+// SelectPgDatabaseSize selects the size of every database.
+func (db *Client) SelectPgDatabaseSize(ctx context.Context) ([]*model.PgDatabaseSize, error) {
+	pgDatabaseSizes := []*model.PgDatabaseSize{}
+	if err := db.Select(ctx, &pgDatabaseSizes, sqlSelectPgDatabaseSize); err != nil {
+		return nil, err
+	}
+	return pgDatabaseSizes, nil
+}
+
+// SelectPgRelationSize selects the size of up to limit of the largest tables and materialized views,
+// of at least minBytes in total.
+func (db *Client) SelectPgRelationSize(ctx context.Context, limit, minBytes int) ([]*model.PgRelationSize, error) {
+	pgRelationSizes := []*model.PgRelationSize{}
+	if limit <= 0 {
+		return pgRelationSizes, nil
+	}
+	if err := db.Select(ctx, &pgRelationSizes, sqlSelectPgRelationSize, limit, minBytes); err != nil {
+		return nil, err
+	}
+	return pgRelationSizes, nil
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+
+	"github.com/odonate/postgres-exporter/exporter/db/model"
+)
+
+const sqlSelectPgStatBgWriter = `
+SELECT
+    current_database() as database,
+    checkpoints_timed,
+    checkpoints_req,
+    checkpoint_write_time / 1000 as checkpoint_write_time_seconds,
+    checkpoint_sync_time / 1000 as checkpoint_sync_time_seconds,
+    buffers_checkpoint,
+    buffers_clean,
+    maxwritten_clean,
+    buffers_backend,
+    buffers_backend_fsync,
+    buffers_alloc
+FROM pg_stat_bgwriter`
+
+// PG17 moved checkpoints to pg_stat_checkpointer, and writes by backends to pg_stat_io.
+const sqlSelectPgStatBgWriter17 = `
+SELECT
+    current_database() as database,
+    c.num_timed as checkpoints_timed,
+    c.num_requested as checkpoints_req,
+    c.write_time / 1000 as checkpoint_write_time_seconds,
+    c.sync_time / 1000 as checkpoint_sync_time_seconds,
+    c.buffers_written as buffers_checkpoint,
+    b.buffers_clean,
+    b.maxwritten_clean,
+    io.writes as buffers_backend,
+    io.fsyncs as buffers_backend_fsync,
+    b.buffers_alloc
+FROM pg_stat_checkpointer c
+CROSS JOIN pg_stat_bgwriter b
+CROSS JOIN (
+    SELECT
+        COALESCE(sum(writes), 0)::bigint as writes,
+        COALESCE(sum(fsyncs), 0)::bigint as fsyncs
+    FROM pg_stat_io
+    WHERE object = 'relation' AND backend_type NOT IN ('checkpointer', 'background writer')
+) io`
+
+// SelectPgStatBgWriter selects stats on the background writer and checkpointer.
+func (db *Client) SelectPgStatBgWriter(ctx context.Context) ([]*model.PgStatBgWriter, error) {
+	pgStatBgWriters := []*model.PgStatBgWriter{}
+	sql := db.sqlForVersion(sqlSelectPgStatBgWriter, map[int]string{170000: sqlSelectPgStatBgWriter17})
+	if err := db.Select(ctx, &pgStatBgWriters, sql); err != nil {
+		return nil, err
+	}
+	return pgStatBgWriters, nil
+}
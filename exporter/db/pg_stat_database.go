@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+
+	"github.com/odonate/postgres-exporter/exporter/db/model"
+)
+
+const sqlSelectPgStatDatabaseColumns = `
+SELECT
+    current_database() as database,
+    datname,
+    numbackends,
+    xact_commit,
+    xact_rollback,
+    blks_read,
+    blks_hit,
+    tup_returned,
+    tup_fetched,
+    tup_inserted,
+    tup_updated,
+    tup_deleted,
+    conflicts,
+    temp_files,
+    temp_bytes,
+    deadlocks,
+    blk_read_time / 1000 as blk_read_time_seconds,
+    blk_write_time / 1000 as blk_write_time_seconds,
+    EXTRACT(EPOCH FROM stats_reset)::float as stats_reset`
+
+// PG12 added a row for shared objects, without a datname.
+const sqlSelectPgStatDatabaseFrom = `
+FROM pg_stat_database
+WHERE datname IS NOT NULL`
+
+const sqlSelectPgStatDatabase = sqlSelectPgStatDatabaseColumns + sqlSelectPgStatDatabaseFrom
+
+// PG12 added checksum_failures.
+const sqlSelectPgStatDatabase12 = sqlSelectPgStatDatabaseColumns + `,
+    checksum_failures` + sqlSelectPgStatDatabaseFrom
+
+// PG14 added session statistics.
+const sqlSelectPgStatDatabase14 = sqlSelectPgStatDatabaseColumns + `,
+    checksum_failures,
+    session_time / 1000 as session_time_seconds,
+    active_time / 1000 as active_time_seconds,
+    idle_in_transaction_time / 1000 as idle_in_transaction_time_seconds,
+    sessions,
+    sessions_abandoned,
+    sessions_fatal,
+    sessions_killed` + sqlSelectPgStatDatabaseFrom
+
+// SelectPgStatDatabase selects stats on databases.
+func (db *Client) SelectPgStatDatabase(ctx context.Context) ([]*model.PgStatDatabase, error) {
+	pgStatDatabases := []*model.PgStatDatabase{}
+	sql := db.sqlForVersion(sqlSelectPgStatDatabase, map[int]string{
+		120000: sqlSelectPgStatDatabase12,
+		140000: sqlSelectPgStatDatabase14,
+	})
+	if err := db.Select(ctx, &pgStatDatabases, sql); err != nil {
+		return nil, err
+	}
+	return pgStatDatabases, nil
+}
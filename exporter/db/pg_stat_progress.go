@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+
+	"github.com/odonate/postgres-exporter/exporter/db/model"
+)
+
+const sqlSelectPgStatProgressVacuum = `
+    SELECT
+        'vacuum' as progress,
+        'VACUUM' as command,
+        pid,
+        datname,
+        relid,
+        phase,
+        heap_blks_total as blocks_total,
+        heap_blks_scanned as blocks_done,
+        NULL::bigint as tuples_total,
+        NULL::bigint as tuples_done
+    FROM pg_stat_progress_vacuum`
+
+// PG12 added pg_stat_progress_create_index and pg_stat_progress_cluster.
+const sqlSelectPgStatProgressCreateIndexAndCluster = `
+    UNION ALL
+    SELECT
+        'create_index' as progress,
+        command,
+        pid,
+        datname,
+        relid,
+        phase,
+        blocks_total,
+        blocks_done,
+        tuples_total,
+        tuples_done
+    FROM pg_stat_progress_create_index
+    UNION ALL
+    SELECT
+        'cluster' as progress,
+        command,
+        pid,
+        datname,
+        relid,
+        phase,
+        heap_blks_total as blocks_total,
+        heap_blks_scanned as blocks_done,
+        NULL::bigint as tuples_total,
+        heap_tuples_scanned as tuples_done
+    FROM pg_stat_progress_cluster`
+
+// PG13 added pg_stat_progress_analyze.
+const sqlSelectPgStatProgressAnalyze = `
+    UNION ALL
+    SELECT
+        'analyze' as progress,
+        'ANALYZE' as command,
+        pid,
+        datname,
+        relid,
+        phase,
+        sample_blks_total as blocks_total,
+        sample_blks_scanned as blocks_done,
+        NULL::bigint as tuples_total,
+        NULL::bigint as tuples_done
+    FROM pg_stat_progress_analyze`
+
+// Relations are only named in the current database, and by OID in others.
+const sqlSelectPgStatProgressColumns = `
+SELECT
+    current_database() as database,
+    p.progress,
+    p.command,
+    p.pid,
+    COALESCE(p.datname, '') as datname,
+    COALESCE(n.nspname, '') as schemaname,
+    COALESCE(c.relname, p.relid::text) as relname,
+    p.phase,
+    p.blocks_total,
+    p.blocks_done,
+    p.tuples_total,
+    p.tuples_done,
+    EXTRACT(EPOCH FROM now() - a.query_start)::float as elapsed_seconds,
+    COALESCE(a.backend_type = 'autovacuum worker', false) as autovacuum
+FROM (`
+
+const sqlSelectPgStatProgressFrom = `
+) p
+LEFT JOIN pg_class c ON c.oid = p.relid AND p.datname = current_database()
+LEFT JOIN pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_stat_activity a ON a.pid = p.pid`
+
+const sqlSelectPgStatProgress = sqlSelectPgStatProgressColumns +
+	sqlSelectPgStatProgressVacuum +
+	sqlSelectPgStatProgressFrom
+
+const sqlSelectPgStatProgress12 = sqlSelectPgStatProgressColumns +
+	sqlSelectPgStatProgressVacuum +
+	sqlSelectPgStatProgressCreateIndexAndCluster +
+	sqlSelectPgStatProgressFrom
+
+const sqlSelectPgStatProgress13 = sqlSelectPgStatProgressColumns +
+	sqlSelectPgStatProgressVacuum +
+	sqlSelectPgStatProgressCreateIndexAndCluster +
+	sqlSelectPgStatProgressAnalyze +
+	sqlSelectPgStatProgressFrom
+
+// SelectPgStatProgress selects the progress of running vacuums, analyzes, index builds and clusters.
+func (db *Client) SelectPgStatProgress(ctx context.Context) ([]*model.PgStatProgress, error) {
+	pgStatProgresses := []*model.PgStatProgress{}
+	sql := db.sqlForVersion(sqlSelectPgStatProgress, map[int]string{
+		120000: sqlSelectPgStatProgress12,
+		130000: sqlSelectPgStatProgress13,
+	})
+	if err := db.Select(ctx, &pgStatProgresses, sql); err != nil {
+		return nil, err
+	}
+	return pgStatProgresses, nil
+}
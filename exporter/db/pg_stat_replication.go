@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+
+	"github.com/odonate/postgres-exporter/exporter/db/model"
+)
+
+// The replay functions are null on primaries, and the receive LSN too on standbys restoring from archives.
+const sqlSelectPgRecovery = `
+SELECT
+    current_database() as database,
+    pg_is_in_recovery() as is_in_recovery,
+    pg_wal_lsn_diff(pg_last_wal_receive_lsn(), pg_last_wal_replay_lsn())::float as receive_replay_lag_bytes,
+    EXTRACT(EPOCH FROM pg_last_xact_replay_timestamp())::float as last_replay_timestamp,
+    CASE
+        WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+        ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp())
+    END::float as replay_delay_seconds`
+
+// pg_current_wal_lsn() cannot be called during recovery, so this is only selected from primaries.
+// Standbys are keyed by application name and address rather than pid, which changes on every reconnection.
+// Of several walsenders sharing them, e.g. a standby and a base backup, the streaming and latest one is kept.
+const sqlSelectPgStatReplication = `
+SELECT DISTINCT ON (application_name, client_addr)
+    current_database() as database,
+    COALESCE(application_name, '') as application_name,
+    COALESCE(host(client_addr), '') as client_addr,
+    COALESCE(state, '') as state,
+    COALESCE(sync_state, '') as sync_state,
+    pg_wal_lsn_diff(pg_current_wal_lsn(), sent_lsn)::float as sent_lag_bytes,
+    pg_wal_lsn_diff(pg_current_wal_lsn(), write_lsn)::float as write_lag_bytes,
+    pg_wal_lsn_diff(pg_current_wal_lsn(), flush_lsn)::float as flush_lag_bytes,
+    pg_wal_lsn_diff(pg_current_wal_lsn(), replay_lsn)::float as replay_lag_bytes,
+    EXTRACT(EPOCH FROM write_lag)::float as write_lag_seconds,
+    EXTRACT(EPOCH FROM flush_lag)::float as flush_lag_seconds,
+    EXTRACT(EPOCH FROM replay_lag)::float as replay_lag_seconds
+FROM pg_stat_replication
+ORDER BY application_name, client_addr, state = 'streaming' DESC, backend_start DESC`
+
+const sqlSelectPgStatWalReceiver = `
+SELECT
+    current_database() as database,
+    COALESCE(status, '') as status,
+    '' as sender_host,
+    COALESCE(slot_name, '') as slot_name
+FROM pg_stat_wal_receiver`
+
+// PG11 added sender_host.
+const sqlSelectPgStatWalReceiver11 = `
+SELECT
+    current_database() as database,
+    COALESCE(status, '') as status,
+    COALESCE(sender_host, '') as sender_host,
+    COALESCE(slot_name, '') as slot_name
+FROM pg_stat_wal_receiver`
+
+// SelectPgRecovery selects whether the server is a standby, along with how far behind its replay is.
+func (db *Client) SelectPgRecovery(ctx context.Context) ([]*model.PgRecovery, error) {
+	pgRecoveries := []*model.PgRecovery{}
+	if err := db.Select(ctx, &pgRecoveries, sqlSelectPgRecovery); err != nil {
+		return nil, err
+	}
+	return pgRecoveries, nil
+}
+
+// SelectPgStatReplication selects stats on the standbys of a primary.
+func (db *Client) SelectPgStatReplication(ctx context.Context) ([]*model.PgStatReplication, error) {
+	pgStatReplications := []*model.PgStatReplication{}
+	if err := db.Select(ctx, &pgStatReplications, sqlSelectPgStatReplication); err != nil {
+		return nil, err
+	}
+	return pgStatReplications, nil
+}
+
+// SelectPgStatWalReceiver selects stats on the WAL receiver of a standby.
+func (db *Client) SelectPgStatWalReceiver(ctx context.Context) ([]*model.PgStatWalReceiver, error) {
+	pgStatWalReceivers := []*model.PgStatWalReceiver{}
+	sql := db.sqlForVersion(sqlSelectPgStatWalReceiver, map[int]string{110000: sqlSelectPgStatWalReceiver11})
+	if err := db.Select(ctx, &pgStatWalReceivers, sql); err != nil {
+		return nil, err
+	}
+	return pgStatWalReceivers, nil
+}
@@ -7,7 +7,8 @@ import (
 	"github.com/odonate/postgres-exporter/exporter/db/model"
 )
 
-const sqlSelectPgStatStatements = `
+// sqlSelectPgStatStatementsColumns are common to every version of pg_stat_statements.
+const sqlSelectPgStatStatementsColumns = `
 SELECT 
     current_database() as database,
     t2.rolname, 
@@ -15,11 +16,6 @@ SELECT
     queryid,
     concat(left(query, 200), '__@',queryid::text, '__', length(query)) as query,
     calls, 
-    total_time / 1000 as total_time_seconds, 
-    min_time / 1000 as min_time_seconds, 
-    max_time / 1000 as max_time_seconds, 
-    mean_time / 1000 as mean_time_seconds, 
-    stddev_time / 1000 as stddev_time_seconds, 
     rows, 
     shared_blks_hit, 
     shared_blks_read, 
@@ -30,19 +26,54 @@ SELECT
     local_blks_dirtied, 
     local_blks_written, 
     temp_blks_read, 
-    temp_blks_written, 
-    blk_read_time / 1000 as blk_read_time_seconds, 
-    blk_write_time / 1000 as blk_write_time_seconds 
+    temp_blks_written, `
+
+const sqlSelectPgStatStatementsFrom = `
 FROM pg_stat_statements t1 
 JOIN pg_roles t2 ON (t1.userid=t2.oid) 
 JOIN pg_database t3 ON (t1.dbid=t3.oid) 
 WHERE t2.rolname != 'rdsadmin'`
 
+const sqlSelectPgStatStatements = sqlSelectPgStatStatementsColumns + `
+    total_time / 1000 as total_time_seconds, 
+    min_time / 1000 as min_time_seconds, 
+    max_time / 1000 as max_time_seconds, 
+    mean_time / 1000 as mean_time_seconds, 
+    stddev_time / 1000 as stddev_time_seconds, 
+    blk_read_time / 1000 as blk_read_time_seconds, 
+    blk_write_time / 1000 as blk_write_time_seconds` + sqlSelectPgStatStatementsFrom
+
+// pg_stat_statements 1.8 split planning from execution times, as total_exec_time etc.
+const sqlSelectPgStatStatements18 = sqlSelectPgStatStatementsColumns + `
+    total_exec_time / 1000 as total_time_seconds, 
+    min_exec_time / 1000 as min_time_seconds, 
+    max_exec_time / 1000 as max_time_seconds, 
+    mean_exec_time / 1000 as mean_time_seconds, 
+    stddev_exec_time / 1000 as stddev_time_seconds, 
+    blk_read_time / 1000 as blk_read_time_seconds, 
+    blk_write_time / 1000 as blk_write_time_seconds` + sqlSelectPgStatStatementsFrom
+
+// pg_stat_statements 1.11 renamed blk_read_time and blk_write_time to shared_blk_read_time etc.
+const sqlSelectPgStatStatements111 = sqlSelectPgStatStatementsColumns + `
+    total_exec_time / 1000 as total_time_seconds, 
+    min_exec_time / 1000 as min_time_seconds, 
+    max_exec_time / 1000 as max_time_seconds, 
+    mean_exec_time / 1000 as mean_time_seconds, 
+    stddev_exec_time / 1000 as stddev_time_seconds, 
+    shared_blk_read_time / 1000 as blk_read_time_seconds, 
+    shared_blk_write_time / 1000 as blk_write_time_seconds` + sqlSelectPgStatStatementsFrom
+
 // SelectPgStatStatements selects stats on user tables.
 func (db *Client) SelectPgStatStatements(ctx context.Context) ([]*model.PgStatStatement, error) {
 	start := time.Now()
 	pgStatStatements := []*model.PgStatStatement{}
-	if err := db.Select(ctx, &pgStatStatements, sqlSelectPgStatStatements); err != nil {
+	// The columns depend on the version of the extension, which may lag behind that of the server
+	// until ALTER EXTENSION pg_stat_statements UPDATE.
+	sql := db.sqlForExtensionVersion("pg_stat_statements", sqlSelectPgStatStatements, map[string]string{
+		"1.8":  sqlSelectPgStatStatements18,
+		"1.11": sqlSelectPgStatStatements111,
+	})
+	if err := db.Select(ctx, &pgStatStatements, sql); err != nil {
 		return nil, err
 	}
 	log.Infof("%s select statements took %dms", db.opts.Database, time.Now().Sub(start).Milliseconds())
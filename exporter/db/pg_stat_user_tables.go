@@ -6,7 +6,7 @@ import (
 	"github.com/odonate/postgres-exporter/exporter/db/model"
 )
 
-const sqlSelectPgStatUserTables = `
+const sqlSelectPgStatUserTablesColumns = `
 SELECT
      current_database() as database,
      schemaname,
@@ -29,13 +29,21 @@ SELECT
      vacuum_count,
      autovacuum_count,
      analyze_count,
-     autoanalyze_count
+     autoanalyze_count`
+
+const sqlSelectPgStatUserTables = sqlSelectPgStatUserTablesColumns + `
+FROM pg_stat_user_tables`
+
+// PG13 added n_ins_since_vacuum.
+const sqlSelectPgStatUserTables13 = sqlSelectPgStatUserTablesColumns + `,
+     n_ins_since_vacuum
 FROM pg_stat_user_tables`
 
 // SelectPgStatUserTables selects stats on user tables.
 func (db *Client) SelectPgStatUserTables(ctx context.Context) ([]*model.PgStatUserTable, error) {
 	pgStatUserTables := []*model.PgStatUserTable{}
-	if err := db.Select(ctx, &pgStatUserTables, sqlSelectPgStatUserTables); err != nil {
+	sql := db.sqlForVersion(sqlSelectPgStatUserTables, map[int]string{130000: sqlSelectPgStatUserTables13})
+	if err := db.Select(ctx, &pgStatUserTables, sql); err != nil {
 		return nil, err
 	}
 	return pgStatUserTables, nil
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"context"
+
+	"github.com/odonate/postgres-exporter/exporter/db/model"
+)
+
+const sqlSelectPgStatArchiver = `
+SELECT
+    current_database() as database,
+    archived_count,
+    COALESCE(last_archived_wal, '') as last_archived_wal,
+    EXTRACT(EPOCH FROM last_archived_time)::float as last_archived_time,
+    failed_count,
+    COALESCE(last_failed_wal, '') as last_failed_wal,
+    EXTRACT(EPOCH FROM last_failed_time)::float as last_failed_time
+FROM pg_stat_archiver`
+
+// The WAL LSN is the current one on primaries, and the replayed one on standbys.
+const sqlSelectPgStatWalLSN = `
+SELECT
+    current_database() as database,
+    pg_wal_lsn_diff(
+        CASE WHEN pg_is_in_recovery() THEN pg_last_wal_replay_lsn() ELSE pg_current_wal_lsn() END,
+        '0/0'
+    )::float as wal_lsn_bytes`
+
+// PG14 added pg_stat_wal.
+const sqlSelectPgStatWal14 = sqlSelectPgStatWalLSN + `,
+    wal_records,
+    wal_fpi,
+    wal_bytes::float as wal_bytes,
+    wal_buffers_full,
+    wal_write,
+    wal_sync,
+    wal_write_time / 1000 as wal_write_time_seconds,
+    wal_sync_time / 1000 as wal_sync_time_seconds
+FROM pg_stat_wal`
+
+// SelectPgStatArchiver selects stats on WAL archiving.
+func (db *Client) SelectPgStatArchiver(ctx context.Context) ([]*model.PgStatArchiver, error) {
+	pgStatArchivers := []*model.PgStatArchiver{}
+	if err := db.Select(ctx, &pgStatArchivers, sqlSelectPgStatArchiver); err != nil {
+		return nil, err
+	}
+	return pgStatArchivers, nil
+}
+
+// SelectPgStatWal selects the current WAL LSN, along with stats on WAL generation from PG14.
+func (db *Client) SelectPgStatWal(ctx context.Context) ([]*model.PgStatWal, error) {
+	pgStatWals := []*model.PgStatWal{}
+	sql := db.sqlForVersion(sqlSelectPgStatWalLSN, map[int]string{140000: sqlSelectPgStatWal14})
+	if err := db.Select(ctx, &pgStatWals, sql); err != nil {
+		return nil, err
+	}
+	return pgStatWals, nil
+}
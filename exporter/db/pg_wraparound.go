@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+
+	"github.com/odonate/postgres-exporter/exporter/db/model"
+)
+
+const sqlSelectPgDatabaseWraparound = `
+SELECT
+    current_database() as database,
+    datname,
+    age(datfrozenxid) as xid_age,
+    mxid_age(datminmxid) as mxid_age,
+    current_setting('autovacuum_freeze_max_age')::bigint as autovacuum_freeze_max_age,
+    current_setting('autovacuum_multixact_freeze_max_age')::bigint as autovacuum_multixact_freeze_max_age
+FROM pg_database`
+
+// Only relations with storage of their own have transaction IDs to freeze. pg_class only holds the relations
+// of the connected database.
+const sqlSelectPgRelationWraparound = `
+SELECT
+    current_database() as database,
+    n.nspname as schemaname,
+    c.relname,
+    age(c.relfrozenxid) as xid_age,
+    mxid_age(c.relminmxid) as mxid_age
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE c.relkind IN ('r', 'm', 't')
+ORDER BY age(c.relfrozenxid) DESC
+LIMIT $1`
+
+// The oldest xmin of every kind of holder, which vacuum cannot freeze past, leaving out the exporter's own
+// transactions: those of its other connections, running other collectors, are recognised by their application
+// name, unless it is unset.
+const sqlSelectPgXminHolders = `
+SELECT DISTINCT ON (holder)
+    current_database() as database,
+    holder,
+    id,
+    xmin_age
+FROM (
+    SELECT
+        'backend' as holder,
+        pid::text as id,
+        greatest(age(backend_xmin), age(backend_xid)) as xmin_age
+    FROM pg_stat_activity
+    WHERE (backend_xmin IS NOT NULL OR backend_xid IS NOT NULL)
+        AND pid <> pg_backend_pid()
+        AND (application_name <> current_setting('application_name') OR current_setting('application_name') = '')
+    UNION ALL
+    SELECT
+        'prepared_transaction' as holder,
+        gid as id,
+        age(transaction) as xmin_age
+    FROM pg_prepared_xacts
+    UNION ALL
+    SELECT
+        'replication_slot' as holder,
+        slot_name::text as id,
+        greatest(age(xmin), age(catalog_xmin)) as xmin_age
+    FROM pg_replication_slots
+    WHERE xmin IS NOT NULL OR catalog_xmin IS NOT NULL
+    UNION ALL
+    SELECT
+        'standby' as holder,
+        COALESCE(application_name, '') as id,
+        age(backend_xmin) as xmin_age
+    FROM pg_stat_replication
+    WHERE backend_xmin IS NOT NULL
+) holders
+ORDER BY holder, xmin_age DESC`
+
+// SelectPgDatabaseWraparound selects the age of the oldest unfrozen transaction and multixact IDs of every database.
+func (db *Client) SelectPgDatabaseWraparound(ctx context.Context) ([]*model.PgDatabaseWraparound, error) {
+	pgDatabaseWraparounds := []*model.PgDatabaseWraparound{}
+	if err := db.Select(ctx, &pgDatabaseWraparounds, sqlSelectPgDatabaseWraparound); err != nil {
+		return nil, err
+	}
+	return pgDatabaseWraparounds, nil
+}
+
+// SelectPgRelationWraparound selects up to limit relations of the connected database with the oldest
+// unfrozen transaction IDs.
+func (db *Client) SelectPgRelationWraparound(ctx context.Context, limit int) ([]*model.PgRelationWraparound, error) {
+	pgRelationWraparounds := []*model.PgRelationWraparound{}
+	if limit <= 0 {
+		return pgRelationWraparounds, nil
+	}
+	if err := db.Select(ctx, &pgRelationWraparounds, sqlSelectPgRelationWraparound, limit); err != nil {
+		return nil, err
+	}
+	return pgRelationWraparounds, nil
+}
+
+// SelectPgXminHolders selects the oldest backend, prepared transaction, replication slot and standby
+// holding back the xmin horizon.
+func (db *Client) SelectPgXminHolders(ctx context.Context) ([]*model.PgXminHolder, error) {
+	pgXminHolders := []*model.PgXminHolder{}
+	if err := db.Select(ctx, &pgXminHolders, sqlSelectPgXminHolders); err != nil {
+		return nil, err
+	}
+	return pgXminHolders, nil
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/odonate/postgres-exporter/exporter/db/model"
+)
+
+const sqlSelectServerVersion = `
+SELECT
+    current_setting('server_version') AS server_version,
+    current_setting('server_version_num')::int AS server_version_num`
+
+const sqlSelectPgExtensions = `SELECT extname, extversion FROM pg_extension`
+
+// detectServer selects the version and installed extensions of the server.
+func (db *Client) detectServer(ctx context.Context) error {
+	versions := []*model.ServerVersion{}
+	if err := db.selectRows(ctx, &versions, sqlSelectServerVersion); err != nil {
+		return fmt.Errorf("selecting server version: %w", err)
+	}
+	if len(versions) != 1 {
+		return fmt.Errorf("selected %d server versions", len(versions))
+	}
+	if err := db.detectExtensions(ctx); err != nil {
+		return err
+	}
+	db.serverVersion = *versions[0]
+	return nil
+}
+
+// detectExtensions selects the extensions installed in the database.
+func (db *Client) detectExtensions(ctx context.Context) error {
+	pgExtensions := []*model.PgExtension{}
+	if err := db.selectRows(ctx, &pgExtensions, sqlSelectPgExtensions); err != nil {
+		return fmt.Errorf("selecting extensions: %w", err)
+	}
+	extensions := make(map[string]string, len(pgExtensions))
+	for _, pgExtension := range pgExtensions {
+		extensions[pgExtension.ExtName] = pgExtension.ExtVersion
+	}
+	db.extensionsMutex.Lock()
+	defer db.extensionsMutex.Unlock()
+	for name := range extensions {
+		if _, ok := db.extensions[name]; !ok && db.extensions != nil {
+			log.Infof("%s extension %s installed", db.Name(), name)
+		}
+	}
+	for name := range db.extensions {
+		if _, ok := extensions[name]; !ok {
+			log.Infof("%s extension %s dropped", db.Name(), name)
+		}
+	}
+	db.extensions = extensions
+	return nil
+}
+
+// ServerVersion returns the version of the server as detected at connect time, e.g. "16.2 (Debian 16.2-1)".
+// It is empty until connected.
+func (db *Client) ServerVersion() string {
+	if !db.Connected() {
+		return ""
+	}
+	return db.serverVersion.ServerVersion
+}
+
+// ServerVersionNum returns the version of the server as a number, e.g. 110005 for 11.5.
+// It is 0 until connected.
+func (db *Client) ServerVersionNum() int {
+	if !db.Connected() {
+		return 0
+	}
+	return db.serverVersion.ServerVersionNum
+}
+
+// ShortServerVersion returns the version of the server without any build details, e.g. 11.5 or 9.6.24.
+func (db *Client) ShortServerVersion() string {
+	num := db.ServerVersionNum()
+	if num >= 100000 {
+		return fmt.Sprintf("%d.%d", num/10000, num%10000)
+	}
+	return fmt.Sprintf("%d.%d.%d", num/10000, num/100%100, num%100)
+}
+
+// HasExtension returns whether the extension was installed in the database when last detected,
+// at connect time or by DetectExtensions.
+func (db *Client) HasExtension(name string) bool {
+	if !db.Connected() {
+		return false
+	}
+	db.extensionsMutex.RLock()
+	defer db.extensionsMutex.RUnlock()
+	_, ok := db.extensions[name]
+	return ok
+}
+
+// LookupExtension returns whether the extension is installed in the database, detecting the
+// extensions afresh if it was not when last detected, so that extensions created after
+// connecting are picked up.
+func (db *Client) LookupExtension(ctx context.Context, name string) (bool, error) {
+	if db.HasExtension(name) {
+		return true, nil
+	}
+	if !db.Connected() {
+		return false, nil
+	}
+	if err := db.DetectExtensions(ctx); err != nil {
+		return false, err
+	}
+	return db.HasExtension(name), nil
+}
+
+// DetectExtensions selects the extensions installed in the database afresh, e.g. once one
+// of them may have been dropped.
+func (db *Client) DetectExtensions(ctx context.Context) error {
+	if err := db.checkConnected(); err != nil {
+		return err
+	}
+	return db.detectExtensions(ctx)
+}
+
+// Extensions returns the versions of the extensions installed in the database when last detected, by name.
+func (db *Client) Extensions() map[string]string {
+	if !db.Connected() {
+		return nil
+	}
+	db.extensionsMutex.RLock()
+	defer db.extensionsMutex.RUnlock()
+	extensions := make(map[string]string, len(db.extensions))
+	for name, version := range db.extensions {
+		extensions[name] = version
+	}
+	return extensions
+}
+
+// sqlForVersion returns the SQL of the latest variant the server is at least the version
+// of, or sql if none. Variants are keyed by minimum server_version_num.
+func (db *Client) sqlForVersion(sql string, variants map[int]string) string {
+	latest := 0
+	for version, variant := range variants {
+		if db.ServerVersionNum() >= version && version > latest {
+			latest, sql = version, variant
+		}
+	}
+	return sql
+}
+
+// sqlForExtensionVersion returns the SQL of the latest variant the installed extension is at least the
+// version of, or sql if none. Variants are keyed by minimum extension version, e.g. 1.8.
+func (db *Client) sqlForExtensionVersion(name, sql string, variants map[string]string) string {
+	installed, ok := db.Extensions()[name]
+	if !ok {
+		return sql
+	}
+	latest := ""
+	for version, variant := range variants {
+		if compareVersions(installed, version) >= 0 && (latest == "" || compareVersions(version, latest) > 0) {
+			latest, sql = version, variant
+		}
+	}
+	return sql
+}
+
+// compareVersions compares dotted versions, such as 1.10 and 1.9, by their numeric parts, returning
+// -1, 0 or 1 as a is older than, the same as or newer than b.
+func compareVersions(a, b string) int {
+	aParts, bParts := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		aPart, bPart := versionPart(aParts, i), versionPart(bParts, i)
+		switch {
+		case aPart < bPart:
+			return -1
+		case aPart > bPart:
+			return 1
+		}
+	}
+	return 0
+}
+
+// versionPart returns the ith numeric part of a version, missing and non-numeric parts being 0.
+func versionPart(parts []string, i int) int {
+	if i >= len(parts) {
+		return 0
+	}
+	part, _ := strconv.Atoi(parts[i])
+	return part
+}
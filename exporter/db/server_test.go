@@ -0,0 +1,53 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "1.8", b: "1.8", want: 0},
+		{a: "1.10", b: "1.9", want: 1},
+		{a: "1.9", b: "1.11", want: -1},
+		{a: "1.8", b: "1.8.0", want: 0},
+		{a: "2.0", b: "1.11", want: 1},
+		{a: "1.4", b: "1.8", want: -1},
+	}
+	for _, test := range tests {
+		if got := compareVersions(test.a, test.b); got != test.want {
+			t.Errorf("compareVersions(%s, %s) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestSQLForExtensionVersion(t *testing.T) {
+	variants := map[string]string{"1.8": "exec_time", "1.11": "shared_blk_time"}
+	tests := []struct {
+		// Installed version of the extension, if any.
+		version string
+		sql     string
+	}{
+		{version: "", sql: "time"},
+		{version: "1.7", sql: "time"},
+		{version: "1.8", sql: "exec_time"},
+		{version: "1.10", sql: "exec_time"},
+		{version: "1.11", sql: "shared_blk_time"},
+		{version: "1.12", sql: "shared_blk_time"},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("version %q", test.version), func(t *testing.T) {
+			client := &Client{connected: make(chan struct{}), extensions: map[string]string{}}
+			close(client.connected)
+			if test.version != "" {
+				client.extensions["pg_stat_statements"] = test.version
+			}
+			if sql := client.sqlForExtensionVersion("pg_stat_statements", "time", variants); sql != test.sql {
+				t.Errorf("sqlForExtensionVersion = %q, want %q", sql, test.sql)
+			}
+		})
+	}
+}
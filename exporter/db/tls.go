@@ -0,0 +1,146 @@
+package db
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+// configureTLS makes the TLS configs parsed from the DSN re-read their certificate files
+// whenever they change, so that rotated certificates are used by new connections, and
+// overrides the server name to verify and send via SNI.
+func configureTLS(config *pgconn.Config, opts Opts) {
+	files := &certFiles{opts: opts}
+	tlsConfigs := []*tls.Config{config.TLSConfig}
+	for _, fallback := range config.Fallbacks {
+		tlsConfigs = append(tlsConfigs, fallback.TLSConfig)
+	}
+	// As in libpq, require behaves as verify-ca given a root certificate.
+	verify := opts.SSLMode == "verify-ca" || opts.SSLMode == "verify-full" || (opts.SSLMode == "require" && opts.SSLRootCert != "")
+	for _, tlsConfig := range tlsConfigs {
+		if tlsConfig == nil {
+			// Fallback without TLS, e.g. for sslmode prefer.
+			continue
+		}
+		if opts.SSLServerName != "" {
+			tlsConfig.ServerName = opts.SSLServerName
+		}
+		if opts.SSLCert != "" {
+			tlsConfig.Certificates = nil
+			tlsConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return files.clientCertificate()
+			}
+		}
+		if verify && opts.SSLRootCert != "" {
+			// Leave the server name empty to skip hostname verification, as in verify-ca.
+			serverName := ""
+			if opts.SSLMode == "verify-full" {
+				serverName = tlsConfig.ServerName
+			}
+			tlsConfig.RootCAs = nil
+			tlsConfig.ClientCAs = nil
+			tlsConfig.InsecureSkipVerify = true
+			tlsConfig.VerifyPeerCertificate = nil
+			tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+				return files.verify(state, serverName)
+			}
+		}
+	}
+}
+
+// certFiles loads the certificate files of a connection, reloading them once modified.
+type certFiles struct {
+	opts  Opts
+	mutex sync.Mutex
+
+	rootCAs        *x509.CertPool
+	rootCAsModTime time.Time
+	cert           *tls.Certificate
+	certModTime    time.Time
+	keyModTime     time.Time
+}
+
+// clientCertificate returns the client certificate and key.
+func (f *certFiles) clientCertificate() (*tls.Certificate, error) {
+	certModTime, err := modTime(f.opts.SSLCert)
+	if err != nil {
+		return nil, err
+	}
+	keyModTime, err := modTime(f.opts.SSLKey)
+	if err != nil {
+		return nil, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.cert != nil && certModTime.Equal(f.certModTime) && keyModTime.Equal(f.keyModTime) {
+		return f.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(f.opts.SSLCert, f.opts.SSLKey)
+	if err != nil {
+		return nil, fmt.Errorf("loading client certificate: %w", err)
+	}
+	log.Infof("loaded client certificate %s", f.opts.SSLCert)
+	f.cert, f.certModTime, f.keyModTime = &cert, certModTime, keyModTime
+	return f.cert, nil
+}
+
+// rootCertPool returns the pool of root certificates to verify the server against.
+func (f *certFiles) rootCertPool() (*x509.CertPool, error) {
+	rootCAsModTime, err := modTime(f.opts.SSLRootCert)
+	if err != nil {
+		return nil, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.rootCAs != nil && rootCAsModTime.Equal(f.rootCAsModTime) {
+		return f.rootCAs, nil
+	}
+	pem, err := os.ReadFile(f.opts.SSLRootCert)
+	if err != nil {
+		return nil, fmt.Errorf("reading root certificate: %w", err)
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in %s", f.opts.SSLRootCert)
+	}
+	log.Infof("loaded root certificate %s", f.opts.SSLRootCert)
+	f.rootCAs, f.rootCAsModTime = rootCAs, rootCAsModTime
+	return f.rootCAs, nil
+}
+
+// verify verifies the certificate chain of the server, and its hostname unless serverName is empty.
+func (f *certFiles) verify(state tls.ConnectionState, serverName string) error {
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("server sent no certificate")
+	}
+	rootCAs, err := f.rootCertPool()
+	if err != nil {
+		return err
+	}
+	opts := x509.VerifyOptions{
+		Roots:         rootCAs,
+		DNSName:       serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err = state.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// modTime returns the modification time of the file, following symlinks such as those
+// of Kubernetes secrets.
+func modTime(path string) (time.Time, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
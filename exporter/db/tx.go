@@ -0,0 +1,119 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// maxTransactionRetryDelay caps the exponential backoff between transaction attempts.
+const maxTransactionRetryDelay = 5 * time.Second
+
+// PostgreSQL error codes of transient failures, after which transactions are retried.
+const (
+	codeSerializationFailure = "40001"
+	codeDeadlockDetected     = "40P01"
+	codeAdminShutdown        = "57P01"
+	codeCrashShutdown        = "57P02"
+	codeCannotConnectNow     = "57P03"
+	// Class of connection exceptions, e.g. 08006 connection_failure.
+	classConnectionException = "08"
+)
+
+// InTx runs fn within a transaction of the client's default isolation level and access
+// mode, retrying it on transient failures.
+func (c *Client) InTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	if err := c.checkConnected(); err != nil {
+		return err
+	}
+	return c.inTx(ctx, c.txOptions, fn)
+}
+
+// ReadTx runs fn within a read-only transaction, retrying it on transient failures.
+func (c *Client) ReadTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	if err := c.checkConnected(); err != nil {
+		return err
+	}
+	return c.readTx(ctx, fn)
+}
+
+func (c *Client) readTx(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	txOptions := c.txOptions
+	txOptions.AccessMode = pgx.ReadOnly
+	return c.inTx(ctx, txOptions, fn)
+}
+
+// inTx runs fn within a transaction, retrying serialization failures, connection resets
+// and shutdowns with exponential backoff and jitter. Attempts are bounded by
+// MaxTransactionAttempts and, along with the delays between them, TotalTransactionTimeout.
+func (c *Client) inTx(ctx context.Context, txOptions pgx.TxOptions, fn func(tx pgx.Tx) error) error {
+	if c.opts.TotalTransactionTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.TotalTransactionTimeout)
+		defer cancel()
+	}
+	for attempt := 1; ; attempt++ {
+		err := c.pool.BeginTxFunc(ctx, txOptions, fn)
+		if err == nil {
+			return nil
+		}
+		c.credentials.invalidate(err)
+		if !isRetryable(ctx, err) || (c.opts.MaxTransactionAttempts >= 0 && attempt >= c.opts.MaxTransactionAttempts) {
+			return err
+		}
+		delay := c.retryDelay(attempt)
+		log.Infof("%s retrying transaction in %dms after attempt %d: %s", c.Name(), delay.Milliseconds(), attempt, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// retryDelay returns the delay following the attempt, starting at InitialTransactionRetryDelay
+// and then doubling from BaseTransactionRetryDelay, randomised by up to half either way.
+func (c *Client) retryDelay(attempt int) time.Duration {
+	delay := c.opts.InitialTransactionRetryDelay
+	if attempt > 1 {
+		delay = c.opts.BaseTransactionRetryDelay
+		for i := 2; i < attempt && delay < maxTransactionRetryDelay; i++ {
+			delay *= 2
+		}
+	}
+	if delay > maxTransactionRetryDelay {
+		delay = maxTransactionRetryDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay)))
+}
+
+// isRetryable returns whether a transaction failed transiently, such that it may succeed if retried.
+func isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case codeSerializationFailure, codeDeadlockDetected, codeAdminShutdown, codeCrashShutdown, codeCannotConnectNow:
+			return true
+		}
+		return strings.HasPrefix(pgErr.Code, classConnectionException)
+	}
+	return pgconn.SafeToRetry(err) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+func TestRetryDelay(t *testing.T) {
+	client := &Client{opts: Opts{
+		InitialTransactionRetryDelay: 10 * time.Millisecond,
+		BaseTransactionRetryDelay:    100 * time.Millisecond,
+	}}
+	tests := []struct {
+		attempt int
+		delay   time.Duration
+	}{
+		{attempt: 1, delay: 10 * time.Millisecond},
+		{attempt: 2, delay: 100 * time.Millisecond},
+		{attempt: 3, delay: 200 * time.Millisecond},
+		{attempt: 4, delay: 400 * time.Millisecond},
+		{attempt: 7, delay: 3200 * time.Millisecond},
+		{attempt: 8, delay: maxTransactionRetryDelay},
+		{attempt: 1000, delay: maxTransactionRetryDelay},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("attempt %d", test.attempt), func(t *testing.T) {
+			// The delay is jittered within [delay/2, 3*delay/2).
+			min, max := test.delay/2, test.delay*3/2
+			for i := 0; i < 100; i++ {
+				if delay := client.retryDelay(test.attempt); delay < min || delay >= max {
+					t.Fatalf("retryDelay(%d) = %s, want within [%s, %s)", test.attempt, delay, min, max)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryDelayZero(t *testing.T) {
+	client := &Client{opts: Opts{InitialTransactionRetryDelay: 0, BaseTransactionRetryDelay: -time.Second}}
+	for _, attempt := range []int{1, 2, 10} {
+		if delay := client.retryDelay(attempt); delay != 0 {
+			t.Errorf("retryDelay(%d) = %s, want 0", attempt, delay)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		err       error
+		retryable bool
+	}{
+		{name: "serialization failure", err: &pgconn.PgError{Code: codeSerializationFailure}, retryable: true},
+		{name: "deadlock detected", err: &pgconn.PgError{Code: codeDeadlockDetected}, retryable: true},
+		{name: "admin shutdown", err: &pgconn.PgError{Code: codeAdminShutdown}, retryable: true},
+		{name: "crash shutdown", err: &pgconn.PgError{Code: codeCrashShutdown}, retryable: true},
+		{name: "cannot connect now", err: &pgconn.PgError{Code: codeCannotConnectNow}, retryable: true},
+		{name: "connection failure", err: &pgconn.PgError{Code: "08006"}, retryable: true},
+		{name: "wrapped", err: fmt.Errorf("selecting: %w", &pgconn.PgError{Code: codeSerializationFailure}), retryable: true},
+		{name: "connection reset", err: fmt.Errorf("reading: %w", syscall.ECONNRESET), retryable: true},
+		{name: "broken pipe", err: syscall.EPIPE, retryable: true},
+		{name: "unexpected EOF", err: io.ErrUnexpectedEOF, retryable: true},
+		{name: "syntax error", err: &pgconn.PgError{Code: "42601"}},
+		{name: "invalid password", err: &pgconn.PgError{Code: codeInvalidPassword}},
+		{name: "query canceled", err: &pgconn.PgError{Code: "57014"}},
+		{name: "other error", err: errors.New("no rows")},
+		{name: "context canceled", err: context.Canceled},
+		{name: "deadline exceeded", err: fmt.Errorf("selecting: %w", context.DeadlineExceeded)},
+		{name: "done context", ctx: canceled, err: &pgconn.PgError{Code: codeSerializationFailure}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := test.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			if retryable := isRetryable(ctx, test.err); retryable != test.retryable {
+				t.Errorf("isRetryable(%v) = %t, want %t", test.err, retryable, test.retryable)
+			}
+		})
+	}
+}
@@ -3,7 +3,9 @@ package exporter
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/odonate/postgres-exporter/exporter/collectors"
@@ -17,21 +19,95 @@ var log = logging.NewLogger()
 
 const namespace = "pg_stat"
 
+const upHelp = "Was the last scrape of the target successful."
+
 // Opts for the exporter.
 type Opts struct {
 	DBOpts []db.Opts
+	// Targets are scraped alongside DBOpts, each with its own labels and collectors.
+	Targets []Target
+	// ProbeDBOpts are inherited by targets probed by DSN, other than credentials. Defaults to the first target's.
+	ProbeDBOpts *db.Opts
+	// ProbeHosts are the hosts that targets probed by DSN may connect to, "*" being any host.
+	// Targets are only probed by name unless given.
+	ProbeHosts []string
+	// ScrapeTimeout bounds scrapes when Prometheus does not set a timeout, zero is unbounded.
+	ScrapeTimeout time.Duration
+	// ScrapeTimeoutOffset is subtracted from the timeout set by Prometheus, leaving time
+	// to send the scraped metrics.
+	ScrapeTimeoutOffset time.Duration
+	// Background scrapes collectors on their own intervals, rather than on every scrape.
+	Background BackgroundOpts
+	// CollectorOpts tune the collectors of targets that do not set their own, including probed ones.
+	// Defaults to collectors.DefaultOpts.
+	CollectorOpts *collectors.Opts
+}
+
+// Target is a database scraped with its own labels and collectors.
+type Target struct {
+	DBOpts db.Opts
+	// Labels are added to every metric scraped from the target.
+	Labels map[string]string
+	// Collectors names the collectors to run, defaulting to collectors.DefaultNames.
+	Collectors []string
+	// CollectorOpts tune the collectors, defaulting to those of the exporter.
+	CollectorOpts *collectors.Opts
+}
+
+// targets returns every target of the opts, including those given as DBOpts.
+func (o Opts) targets() []Target {
+	collectorOpts := o.collectorOpts()
+	targets := make([]Target, 0, len(o.DBOpts)+len(o.Targets))
+	for _, dbOpts := range o.DBOpts {
+		targets = append(targets, Target{DBOpts: dbOpts, CollectorOpts: &collectorOpts})
+	}
+	for _, target := range o.Targets {
+		if target.CollectorOpts == nil {
+			target.CollectorOpts = &collectorOpts
+		}
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+// collectorOpts returns the opts of the collectors of targets that do not set their own.
+func (o Opts) collectorOpts() collectors.Opts {
+	if o.CollectorOpts == nil {
+		return collectors.DefaultOpts()
+	}
+	return *o.CollectorOpts
 }
 
 // Exporter collects PostgreSQL metrics and exports them via prometheus.
 type Exporter struct {
-	dbClients  []*db.Client
+	opts       Opts
+	targets    []*target
+	factories  []collectors.Factory
 	collectors []collectors.Collector
 
+	// Clients of targets scraped through the probe handler.
+	probeClients map[string]*probeClient
+	probeMutex   sync.Mutex
+
 	// Internal metrics.
-	up           prometheus.Gauge
-	totalScrapes prometheus.Counter
+	up                      *prometheus.Desc
+	static                  *prometheus.Desc
+	scrapeCollectorSuccess  *prometheus.Desc
+	scrapeCollectorDuration *prometheus.Desc
+	scrapeCollectorErrors   *prometheus.CounterVec
+	totalScrapes            prometheus.Counter
+	poolMetrics             poolMetrics
+	// Background scrape metrics.
+	lastScrapeTimestamp *prometheus.Desc
+	cacheAge            *prometheus.Desc
+	// Reload metrics, nil for probed exporters.
+	lastReloadSuccess   prometheus.Gauge
+	lastReloadTimestamp prometheus.Gauge
+	// Whether the exporter was registered with the default registry, which Handler then serves alone.
+	registered bool
 
-	mutex sync.RWMutex
+	mutex       sync.RWMutex
+	reloadMutex sync.Mutex
 }
 
 // MustNew instantiates and returns a new Exporter or panics.
@@ -43,62 +119,323 @@ func MustNew(ctx context.Context, opts Opts) *Exporter {
 	return exporter
 }
 
-// New instaniates and returns a new Exporter.
+// New instaniates and returns a new Exporter. Databases that are down are connected to in the
+// background, so only invalid opts fail.
 func New(ctx context.Context, opts Opts) (*Exporter, error) {
-	if len(opts.DBOpts) < 1 {
+	targetOpts := opts.targets()
+	if len(targetOpts) < 1 {
 		return nil, fmt.Errorf("missing db opts")
 	}
-	dbClients := make([]*db.Client, 0, len(opts.DBOpts))
-	for _, dbOpt := range opts.DBOpts {
-		dbClient, err := db.New(ctx, dbOpt)
+	if err := checkNames(targetOpts); err != nil {
+		return nil, fmt.Errorf("creating exporter: %w", err)
+	}
+	if err := checkBackground(targetOpts, opts.Background); err != nil {
+		return nil, fmt.Errorf("creating exporter: %w", err)
+	}
+	targets := make([]*target, 0, len(targetOpts))
+	for _, opts := range targetOpts {
+		target, err := newTarget(opts, nil)
 		if err != nil {
+			for _, target := range targets {
+				target.close()
+			}
 			return nil, fmt.Errorf("creating exporter: %w", err)
 		}
-		dbClients = append(dbClients, dbClient)
+		targets = append(targets, target)
+	}
+	exporter := newExporter(targets)
+	exporter.opts = opts
+	exporter.probeClients = make(map[string]*probeClient)
+	exporter.lastReloadSuccess = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "exporter_config_last_reload_successful",
+		Help:      "Was the last reload of the exporter's targets successful.",
+	})
+	exporter.lastReloadTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "exporter_config_last_reload_success_timestamp_seconds",
+		Help:      "Timestamp of the last successful reload of the exporter's targets.",
+	})
+	exporter.lastReloadSuccess.Set(1)
+	exporter.lastReloadTimestamp.SetToCurrentTime()
+	for _, target := range targets {
+		target.background = exporter.startBackground(target, opts.Background)
 	}
+	return exporter, nil
+}
+
+func newExporter(targets []*target) *Exporter {
 	return &Exporter{
-		dbClients:  dbClients,
-		collectors: collectors.DefaultCollectors(dbClients),
+		targets: targets,
 
 		// Internal metrics.
-		up: prometheus.NewGauge(prometheus.GaugeOpts{
+		up: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "up"),
+			upHelp,
+			[]string{"target"},
+			nil,
+		),
+		static: prometheus.NewDesc(
+			"pg_static",
+			"Version of the PostgreSQL server of the target.",
+			[]string{"target", "version", "short_version"},
+			nil,
+		),
+		scrapeCollectorSuccess: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "scrape", "collector_success"),
+			"Whether a collector succeeded during the last scrape of the target.",
+			[]string{"collector", "target"},
+			nil,
+		),
+		scrapeCollectorDuration: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "scrape", "collector_duration_seconds"),
+			"Duration of a collector's last scrape of the target.",
+			[]string{"collector", "target"},
+			nil,
+		),
+		scrapeCollectorErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: namespace,
-			Name:      "up",
-			Help:      "Was the last scrape of PostgreSQL successful.",
-		}),
+			Subsystem: "scrape",
+			Name:      "collector_errors_total",
+			Help:      "Total errors of a collector scraping the target, by class of error.",
+		}, []string{"collector", "target", "class"}),
 		totalScrapes: prometheus.NewCounter(prometheus.CounterOpts{
 			Namespace: namespace,
 			Name:      "exporter_scrapes_total",
 			Help:      "Current total PostgreSQL scrapes",
 		}),
-	}, nil
+		poolMetrics: newPoolMetrics(),
+		lastScrapeTimestamp: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "last_scrape_timestamp_seconds"),
+			"Timestamp of a collector's last background scrape of the target.",
+			[]string{"collector", "target"},
+			nil,
+		),
+		cacheAge: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "cache_age_seconds"),
+			"Age of the snapshot of a collector's last good background scrape of the target.",
+			[]string{"collector", "target"},
+			nil,
+		),
+	}
 }
 
 // WithCustomCollectors lets the exporter scrape custom metrics.
+// These are not run against probed targets, see WithCustomCollectorFactories.
 func (e *Exporter) WithCustomCollectors(collectors ...collectors.Collector) *Exporter {
 	e.collectors = append(e.collectors, collectors...)
 	return e
 }
 
-// Register the exporter.
-func (e *Exporter) Register() { prometheus.MustRegister(e) }
+// WithCustomCollectorFactories lets the exporter scrape custom metrics from every
+// target, including probed ones.
+func (e *Exporter) WithCustomCollectorFactories(factories ...collectors.Factory) *Exporter {
+	e.factories = append(e.factories, factories...)
+	for _, target := range e.targets {
+		target.collectors = append(target.collectors, newNamedCollectors([]*db.Client{target.dbClient}, factories...)...)
+		if target.background != nil {
+			target.background.stop()
+			target.background = e.startBackground(target, e.opts.Background)
+		}
+	}
+	return e
+}
+
+// Reload atomically swaps the targets of the exporter for those of opts.
+// Unchanged targets are kept, new ones are connected to in the background, and removed ones
+// are closed once in-flight scrapes and probes are done with them. Targets of which only the labels or
+// collectors changed are rebuilt over their current connections. The current targets are kept if any
+// new one is invalid.
+// Background scrapes of kept targets are restarted if their opts changed, and idle clients of
+// probed targets are closed so that they are connected to afresh with the new opts.
+func (e *Exporter) Reload(ctx context.Context, opts Opts) error {
+	return e.loadAndReload(ctx, func() (Opts, error) { return opts, nil })
+}
+
+// loadAndReload reloads the exporter with the opts returned by load, reporting failures to load
+// them as failed reloads.
+func (e *Exporter) loadAndReload(ctx context.Context, load func() (Opts, error)) error {
+	e.reloadMutex.Lock()
+	defer e.reloadMutex.Unlock()
+	opts, err := load()
+	if err != nil {
+		e.lastReloadSuccess.Set(0)
+		return err
+	}
+	if err := e.reload(ctx, opts); err != nil {
+		e.lastReloadSuccess.Set(0)
+		return fmt.Errorf("reloading exporter: %w", err)
+	}
+	e.lastReloadSuccess.Set(1)
+	e.lastReloadTimestamp.SetToCurrentTime()
+	return nil
+}
+
+func (e *Exporter) reload(ctx context.Context, opts Opts) error {
+	targetOpts := opts.targets()
+	if len(targetOpts) < 1 {
+		return fmt.Errorf("missing db opts")
+	}
+	if err := checkNames(targetOpts); err != nil {
+		return err
+	}
+	if err := checkBackground(targetOpts, opts.Background); err != nil {
+		return err
+	}
+	e.mutex.RLock()
+	current := e.targets
+	backgroundChanged := !reflect.DeepEqual(e.opts.Background, opts.Background)
+	e.mutex.RUnlock()
+
+	kept := make(map[*target]*background, len(current))
+	// Targets whose collectors changed, rebuilt over the connections of the current ones.
+	rebuilt := make(map[*target]*target, len(current))
+	targets := make([]*target, 0, len(targetOpts))
+	var opened []*target
+	for _, targetOpts := range targetOpts {
+		currentTarget := findTarget(current, targetOpts.DBOpts)
+		if currentTarget != nil && reflect.DeepEqual(currentTarget.opts, targetOpts) {
+			kept[currentTarget] = currentTarget.background
+			targets = append(targets, currentTarget)
+			continue
+		}
+		var target *target
+		var err error
+		if currentTarget != nil {
+			target, err = currentTarget.rebuild(targetOpts, e.factories)
+		} else {
+			target, err = newTarget(targetOpts, e.factories)
+		}
+		if err != nil {
+			for _, target := range rebuilt {
+				target.background.stop()
+			}
+			for _, target := range opened {
+				target.close()
+			}
+			return err
+		}
+		target.background = e.startBackground(target, opts.Background)
+		if currentTarget != nil {
+			rebuilt[currentTarget] = target
+		} else {
+			opened = append(opened, target)
+		}
+		targets = append(targets, target)
+	}
+
+	// Waits for in-flight scrapes to finish.
+	e.mutex.Lock()
+	e.opts = opts
+	e.targets = targets
+	if backgroundChanged {
+		for target := range kept {
+			target.background = e.startBackground(target, opts.Background)
+		}
+	}
+	e.mutex.Unlock()
 
-// HealthCheck pings PostgreSQL.
+	for _, target := range current {
+		if background, ok := kept[target]; ok {
+			if backgroundChanged {
+				background.stop()
+			}
+		} else if _, ok := rebuilt[target]; ok {
+			// The connections are now the rebuilt target's.
+			target.background.stop()
+		} else {
+			target.close()
+		}
+	}
+	// Probed targets are connected to afresh with the new probe opts.
+	e.evictProbeClients(time.Now())
+	log.Infof("reloaded exporter: %d targets opened, %d rebuilt, %d closed", len(opened), len(rebuilt), len(current)-len(kept)-len(rebuilt))
+	return nil
+}
+
+// findTarget returns the target connecting with the db opts, if any.
+func findTarget(targets []*target, opts db.Opts) *target {
+	for _, target := range targets {
+		if reflect.DeepEqual(target.opts.DBOpts, opts) {
+			return target
+		}
+	}
+	return nil
+}
+
+// Register the exporter with the default registry.
+//
+// Deprecated: serve Handler instead, which bounds scrapes by their timeout. Once registered,
+// Handler serves the default registry alone, so that the exporter is not collected twice.
+func (e *Exporter) Register() {
+	prometheus.MustRegister(e)
+	e.mutex.Lock()
+	e.registered = true
+	e.mutex.Unlock()
+}
+
+// HealthCheck pings the database of every target, failing if any is down. Targets yet to be
+// connected to fail without an attempt at connecting, which is left to their backoff.
+// The exporter keeps serving the other targets meanwhile, so this suits readiness rather than
+// liveness probes.
 func (e *Exporter) HealthCheck(ctx context.Context) error {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
 	group := errgroup.Group{}
-	for _, dbClient := range e.dbClients {
+	for _, target := range e.targets {
 		ctx := ctx
-		dbClient := dbClient
-		group.Go(func() error { return dbClient.CheckConnection(ctx) })
+		target := target
+		group.Go(func() error {
+			if !target.dbClient.Connected() {
+				return fmt.Errorf("%s: %w", target.name(), db.ErrNotConnected)
+			}
+			if err := target.dbClient.CheckConnection(ctx); err != nil {
+				return fmt.Errorf("%s: %w", target.name(), err)
+			}
+			return nil
+		})
 	}
 	return group.Wait()
 }
 
+// Close closes the connection pools of every db client, including probed ones.
+func (e *Exporter) Close() {
+	e.mutex.Lock()
+	for _, target := range e.targets {
+		target.close()
+	}
+	e.mutex.Unlock()
+	e.probeMutex.Lock()
+	defer e.probeMutex.Unlock()
+	for target, client := range e.probeClients {
+		client.dbClient.Close()
+		delete(e.probeClients, target)
+	}
+}
+
 // Describe implements the prometheus.Collector.
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	// Internal metrics.
-	ch <- e.up.Desc()
+	ch <- e.up
+	ch <- e.static
+	ch <- e.scrapeCollectorSuccess
+	ch <- e.scrapeCollectorDuration
+	e.scrapeCollectorErrors.Describe(ch)
 	ch <- e.totalScrapes.Desc()
+	e.poolMetrics.describe(ch)
+	ch <- e.lastScrapeTimestamp
+	ch <- e.cacheAge
+	if e.lastReloadSuccess != nil {
+		ch <- e.lastReloadSuccess.Desc()
+		ch <- e.lastReloadTimestamp.Desc()
+	}
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	for _, target := range e.targets {
+		for _, collector := range target.collectors {
+			collector.Describe(ch)
+		}
+	}
 	for _, collector := range e.collectors {
 		collector.Describe(ch)
 	}
@@ -106,6 +443,20 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect implements the promtheus.Collector.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
+	e.mutex.RLock()
+	timeout := e.opts.ScrapeTimeout
+	e.mutex.RUnlock()
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	e.collect(ctx, ch)
+}
+
+// collect scrapes every target within the deadline of the context.
+func (e *Exporter) collect(ctx context.Context, ch chan<- prometheus.Metric) {
 	start := time.Now()
 	defer func() {
 		log.Infof("exporter collect took %dms", time.Now().Sub(start).Milliseconds())
@@ -114,16 +465,139 @@ func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
 	defer e.mutex.Unlock()
 
 	e.totalScrapes.Inc()
-	up := 1
-	group := errgroup.Group{}
+	var wg sync.WaitGroup
+	for _, target := range e.targets {
+		target := target
+		e.poolMetrics.collect(target, ch)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Probed exporters leave background opts unset, scraping live.
+			if e.opts.Background.Enabled && target.background != nil {
+				e.serveBackground(target, target.background, e.opts.Background.MaxStaleness, ch)
+				return
+			}
+			e.scrapeTarget(ctx, target, ch)
+		}()
+	}
+	// Custom collectors span every target.
 	for _, collector := range e.collectors {
+		collector := namedCollector{Collector: collector, name: collectorName(collector)}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.scrapeCollector(ctx, collector, nil, ch)
+		}()
+	}
+	wg.Wait()
+	e.scrapeCollectorErrors.Collect(ch)
+	ch <- e.totalScrapes
+	if e.lastReloadSuccess != nil {
+		ch <- e.lastReloadSuccess
+		ch <- e.lastReloadTimestamp
+	}
+}
+
+// scrapeTarget runs every collector of the target, reporting the target as up if all succeed.
+func (e *Exporter) scrapeTarget(ctx context.Context, target *target, ch chan<- prometheus.Metric) {
+	if !target.dbClient.Connected() {
+		// Collectors would fail until connected.
+		e.reportTarget(target, false, ch)
+		return
+	}
+	var failures int32
+	var wg sync.WaitGroup
+	for _, collector := range target.collectors {
 		collector := collector
-		group.Go(func() error { return collector.Scrape(ch) })
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !e.scrapeCollector(ctx, collector, target, ch) {
+				atomic.AddInt32(&failures, 1)
+			}
+		}()
 	}
-	if err := group.Wait(); err != nil {
-		up = 0
-		fmt.Println(fmt.Sprintf("collecting: %w", err))
+	wg.Wait()
+	e.reportTarget(target, failures == 0, ch)
+}
+
+// reportTarget reports whether the target is up, along with the version of its server once connected.
+func (e *Exporter) reportTarget(target *target, up bool, ch chan<- prometheus.Metric) {
+	value := 0
+	if up {
+		value = 1
 	}
-	ch <- prometheus.MustNewConstMetric(e.up.Desc(), prometheus.GaugeValue, float64(up))
-	ch <- e.totalScrapes
+	ch <- prometheus.MustNewConstMetric(e.up, prometheus.GaugeValue, float64(value), target.name())
+	dbClient := target.dbClient
+	if !dbClient.Connected() {
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(e.static, prometheus.GaugeValue, 1, target.name(), dbClient.ServerVersion(), dbClient.ShortServerVersion())
+}
+
+// scrapeCollector runs a collector, of the target unless nil, reporting its success and duration.
+func (e *Exporter) scrapeCollector(ctx context.Context, collector namedCollector, target *target, ch chan<- prometheus.Metric) bool {
+	start := time.Now()
+	err := e.runCollector(ctx, collector, target, func(metric prometheus.Metric) { ch <- metric })
+	targetName := ""
+	if target != nil {
+		targetName = target.name()
+	}
+	e.reportCollector(collector.name, targetName, err, time.Since(start), ch)
+	return err == nil
+}
+
+// runCollector runs a collector, of the target unless nil, emitting its metrics and
+// counting its errors. A collector still running when the context is done is reported as
+// timed out, and its remaining metrics are discarded.
+func (e *Exporter) runCollector(ctx context.Context, collector namedCollector, target *target, emit func(prometheus.Metric)) error {
+	metrics := make(chan prometheus.Metric)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- collector.Scrape(ctx, metrics)
+		close(metrics)
+	}()
+	var err error
+forward:
+	for {
+		select {
+		case metric, ok := <-metrics:
+			if !ok {
+				err = <-errCh
+				break forward
+			}
+			if target != nil {
+				metric = target.label(metric)
+			}
+			emit(metric)
+		case <-ctx.Done():
+			// Let the collector finish without holding up the scrape.
+			go func() {
+				for range metrics {
+				}
+			}()
+			err = ctx.Err()
+			break forward
+		}
+	}
+	if err != nil {
+		targetName := ""
+		if target != nil {
+			targetName = target.name()
+		}
+		class := db.ClassifyError(err)
+		e.scrapeCollectorErrors.WithLabelValues(collector.name, targetName, class).Inc()
+		log.Errorf("%s collector %s failed (%s): %s", targetName, collector.name, class, err)
+	}
+	return err
+}
+
+// reportCollector reports the success and duration of a collector's last scrape of the target.
+func (e *Exporter) reportCollector(collector, targetName string, err error, duration time.Duration, ch chan<- prometheus.Metric) {
+	success := 1
+	if err != nil {
+		success = 0
+	}
+	ch <- prometheus.MustNewConstMetric(e.scrapeCollectorSuccess, prometheus.GaugeValue, float64(success), collector, targetName)
+	ch <- prometheus.MustNewConstMetric(e.scrapeCollectorDuration, prometheus.GaugeValue, duration.Seconds(), collector, targetName)
 }
@@ -0,0 +1,319 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgconn"
+	"github.com/odonate/postgres-exporter/exporter/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// testTarget returns a target of a database nothing listens on, which is never connected to.
+func testTarget(name string) Target {
+	return Target{
+		DBOpts: db.Opts{
+			Name:                  name,
+			Host:                  "127.0.0.1",
+			Port:                  1,
+			User:                  "postgres",
+			Password:              "postgres",
+			Database:              "postgres",
+			ApplicationName:       "postgres-exporter-test",
+			SSLMode:               "disable",
+			ConnectTimeout:        time.Second,
+			StatementTimeout:      time.Second,
+			PoolMaxConns:          1,
+			PoolMaxConnLifetime:   time.Hour,
+			PoolMaxConnIdleTime:   time.Hour,
+			PoolHealthCheckPeriod: time.Hour,
+		},
+		Collectors: []string{"pg_stat_database"},
+	}
+}
+
+func newTestExporter(t *testing.T, opts Opts) *Exporter {
+	t.Helper()
+	e, err := New(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(e.Close)
+	return e
+}
+
+// isClosed returns whether the pool of the client was closed.
+func isClosed(dbClient *db.Client) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := dbClient.CheckConnection(ctx)
+	return err != nil && strings.Contains(err.Error(), "closed pool")
+}
+
+func TestReload(t *testing.T) {
+	e := newTestExporter(t, Opts{Targets: []Target{testTarget("kept"), testTarget("rebuilt"), testTarget("removed")}})
+	kept, rebuilt, removed := e.targets[0], e.targets[1], e.targets[2]
+
+	relabelled := testTarget("rebuilt")
+	relabelled.Labels = map[string]string{"team": "identity"}
+	if err := e.Reload(context.Background(), Opts{Targets: []Target{testTarget("kept"), relabelled, testTarget("added")}}); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+
+	if len(e.targets) != 3 {
+		t.Fatalf("got %d targets, want 3", len(e.targets))
+	}
+	if e.targets[0] != kept {
+		t.Errorf("unchanged target was not kept")
+	}
+	if e.targets[1] == rebuilt || e.targets[1].dbClient != rebuilt.dbClient {
+		t.Errorf("relabelled target was not rebuilt over its connections")
+	}
+	if !hasLabel(e.targets[1].labels, "team") {
+		t.Errorf("rebuilt target labels = %v, want team", e.targets[1].labels)
+	}
+	if added := e.targets[2]; added.name() != "added" || added.dbClient == removed.dbClient {
+		t.Errorf("added target was not opened")
+	}
+	if !isClosed(removed.dbClient) {
+		t.Errorf("removed target was not closed")
+	}
+	for _, target := range e.targets {
+		if isClosed(target.dbClient) {
+			t.Errorf("target %s was closed", target.name())
+		}
+	}
+	if value := testutil.ToFloat64(e.lastReloadSuccess); value != 1 {
+		t.Errorf("last reload successful = %v, want 1", value)
+	}
+}
+
+func TestReloadInvalid(t *testing.T) {
+	invalid := testTarget("invalid")
+	invalid.Collectors = []string{"pg_nope"}
+	relabelled := testTarget("current")
+	relabelled.Labels = map[string]string{"team": "identity"}
+	duplicate := testTarget("current")
+	duplicate.DBOpts.Port = 2
+	bloat := testTarget("bloat")
+	bloat.Collectors = []string{"pg_bloat"}
+	reservedLabel := testTarget("reserved")
+	reservedLabel.Labels = map[string]string{"datname": "orders"}
+
+	tests := []struct {
+		name    string
+		targets []Target
+		err     string
+	}{
+		{name: "no targets", err: "missing db opts"},
+		{name: "unknown collector", targets: []Target{relabelled, testTarget("added"), invalid}, err: `unknown collector "pg_nope"`},
+		{name: "duplicate name", targets: []Target{testTarget("current"), duplicate}, err: `duplicate target "current"`},
+		{name: "bloat without background", targets: []Target{bloat}, err: "bloat: collector pg_bloat requires background scrapes"},
+		{name: "reserved label", targets: []Target{reservedLabel}, err: `label "datname" is reserved by the exporter`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := newTestExporter(t, Opts{Targets: []Target{testTarget("current")}})
+			current := e.targets[0]
+			err := e.Reload(context.Background(), Opts{Targets: test.targets})
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("Reload error = %v, want %q", err, test.err)
+			}
+			// The current targets are kept as they were.
+			if len(e.targets) != 1 || e.targets[0] != current || len(current.labels) != 1 {
+				t.Errorf("targets changed by a failed reload")
+			}
+			if isClosed(current.dbClient) {
+				t.Errorf("current target was closed by a failed reload")
+			}
+			if value := testutil.ToFloat64(e.lastReloadSuccess); value != 0 {
+				t.Errorf("last reload successful = %v, want 0", value)
+			}
+		})
+	}
+}
+
+func TestReloaderLoadError(t *testing.T) {
+	e := newTestExporter(t, Opts{Targets: []Target{testTarget("current")}})
+	current := e.targets[0]
+	errLoad := errors.New("invalid config")
+	reloader := NewReloader(e, func() (Opts, error) { return Opts{}, errLoad })
+	if err := reloader.Reload(context.Background()); !errors.Is(err, errLoad) {
+		t.Fatalf("Reload error = %v, want %v", err, errLoad)
+	}
+	if e.targets[0] != current {
+		t.Errorf("targets changed by a failed load")
+	}
+	if value := testutil.ToFloat64(e.lastReloadSuccess); value != 0 {
+		t.Errorf("last reload successful = %v, want 0", value)
+	}
+}
+
+func TestReloadBackground(t *testing.T) {
+	background := BackgroundOpts{Enabled: true, Interval: time.Hour}
+	e := newTestExporter(t, Opts{Targets: []Target{testTarget("kept")}, Background: background})
+	kept := e.targets[0]
+	started := kept.background
+	if started == nil {
+		t.Fatal("background scrapes were not started")
+	}
+
+	if err := e.Reload(context.Background(), Opts{Targets: []Target{testTarget("kept")}, Background: background}); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if e.targets[0] != kept || kept.background != started {
+		t.Errorf("background scrapes were restarted with unchanged opts")
+	}
+
+	background.Interval = 2 * time.Hour
+	if err := e.Reload(context.Background(), Opts{Targets: []Target{testTarget("kept")}, Background: background}); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if e.targets[0] != kept || kept.background == started {
+		t.Errorf("background scrapes were not restarted with changed opts")
+	}
+	if interval := kept.background.scrapers[0].interval; interval != 2*time.Hour {
+		t.Errorf("background interval = %s, want 2h", interval)
+	}
+
+	// pg_bloat is allowed along with background scrapes.
+	bloat := testTarget("bloat")
+	bloat.Collectors = []string{"pg_bloat"}
+	if err := e.Reload(context.Background(), Opts{Targets: []Target{bloat}, Background: background}); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if interval := e.targets[0].background.scrapers[0].interval; interval != time.Hour {
+		t.Errorf("pg_bloat interval = %s, want 1h", interval)
+	}
+}
+
+func TestReloadEvictsProbeClients(t *testing.T) {
+	e := newTestExporter(t, Opts{Targets: []Target{testTarget("current")}})
+	idle, inUse := newTestProbeClient(t, "idle"), newTestProbeClient(t, "in-use")
+	idle.lastUsed = time.Now()
+	inUse.inUse = 1
+	e.probeClients["idle"], e.probeClients["in-use"] = idle, inUse
+
+	if err := e.Reload(context.Background(), Opts{Targets: []Target{testTarget("current")}}); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if _, ok := e.probeClients["idle"]; ok || !isClosed(idle.dbClient) {
+		t.Errorf("idle probe client was not evicted")
+	}
+	if _, ok := e.probeClients["in-use"]; !ok || isClosed(inUse.dbClient) {
+		t.Errorf("probe client in use was evicted")
+	}
+}
+
+// newTestProbeClient returns the client of a probed target nothing listens on.
+func newTestProbeClient(t *testing.T, name string) *probeClient {
+	t.Helper()
+	dbClient, err := db.NewLazy(testTarget(name).DBOpts)
+	if err != nil {
+		t.Fatalf("NewLazy: %v", err)
+	}
+	t.Cleanup(dbClient.Close)
+	return &probeClient{dbClient: dbClient}
+}
+
+// fakeCollector emits its metrics then fails with its error, or, if blocking, only once the
+// context is done.
+type fakeCollector struct {
+	metrics  []prometheus.Metric
+	err      error
+	blocking bool
+}
+
+func (c fakeCollector) Describe(chan<- *prometheus.Desc) {}
+
+func (c fakeCollector) Collect(chan<- prometheus.Metric) {}
+
+func (c fakeCollector) Scrape(ctx context.Context, ch chan<- prometheus.Metric) error {
+	if c.blocking {
+		<-ctx.Done()
+	}
+	for _, metric := range c.metrics {
+		ch <- metric
+	}
+	if c.blocking {
+		return ctx.Err()
+	}
+	return c.err
+}
+
+func TestRunCollector(t *testing.T) {
+	desc := prometheus.NewDesc("pg_test", "Metric of a test.", nil, nil)
+	metrics := []prometheus.Metric{
+		prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1),
+		prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 2),
+	}
+	tests := []struct {
+		name      string
+		collector fakeCollector
+		err       error
+		class     string
+		emitted   int
+	}{
+		{name: "success", collector: fakeCollector{metrics: metrics}, emitted: 2},
+		{
+			name:      "permission denied",
+			collector: fakeCollector{metrics: metrics[:1], err: &pgconn.PgError{Code: "42501"}},
+			class:     db.ErrorClassPermissionDenied,
+			emitted:   1,
+		},
+		{
+			name:      "statement timeout",
+			collector: fakeCollector{err: fmt.Errorf("selecting: %w", &pgconn.PgError{Code: "57014"})},
+			class:     db.ErrorClassTimeout,
+		},
+		{
+			name:      "deadline exceeded",
+			collector: fakeCollector{metrics: metrics, blocking: true},
+			err:       context.DeadlineExceeded,
+			class:     db.ErrorClassTimeout,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := newExporter(nil)
+			target := &target{dbClient: newTestProbeClient(t, "orders").dbClient, labels: labelPairs("orders", nil)}
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			var emitted []prometheus.Metric
+			err := e.runCollector(ctx, namedCollector{Collector: test.collector, name: "pg_test"}, target, func(metric prometheus.Metric) {
+				emitted = append(emitted, metric)
+			})
+			if (err != nil) != (test.class != "") || test.err != nil && !errors.Is(err, test.err) {
+				t.Errorf("runCollector error = %v, want %v", err, test.err)
+			}
+			if len(emitted) != test.emitted {
+				t.Errorf("emitted %d metrics, want %d", len(emitted), test.emitted)
+			}
+			for _, metric := range emitted {
+				var out dto.Metric
+				if err := metric.Write(&out); err != nil {
+					t.Fatal(err)
+				}
+				if pairs := out.GetLabel(); len(pairs) != 1 || pairs[0].GetName() != "target" || pairs[0].GetValue() != "orders" {
+					t.Errorf("metric labels = %v, want the target", pairs)
+				}
+			}
+			for _, class := range []string{db.ErrorClassTimeout, db.ErrorClassPermissionDenied} {
+				want := 0.0
+				if class == test.class {
+					want = 1
+				}
+				if value := testutil.ToFloat64(e.scrapeCollectorErrors.WithLabelValues("pg_test", "orders", class)); value != want {
+					t.Errorf("%s errors = %v, want %v", class, value, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// scrapeTimeoutHeader is set by Prometheus to the scrape timeout in seconds.
+const scrapeTimeoutHeader = "X-Prometheus-Scrape-Timeout-Seconds"
+
+// Handler serves the metrics of the exporter, along with those of the default registry.
+// Scrapes are cancelled along with the request and bounded by the timeout Prometheus sets,
+// unless the exporter was registered, in which case only the default registry is served.
+func (e *Exporter) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e.mutex.RLock()
+		registered := e.registered
+		e.mutex.RUnlock()
+		if registered {
+			promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := e.scrapeContext(r)
+		defer cancel()
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(contextCollector{ctx: ctx, exporter: e})
+		gatherers := prometheus.Gatherers{prometheus.DefaultGatherer, registry}
+		promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
+
+// scrapeContext derives the context of a scrape from the request.
+func (e *Exporter) scrapeContext(r *http.Request) (context.Context, context.CancelFunc) {
+	e.mutex.RLock()
+	timeout, offset := e.opts.ScrapeTimeout, e.opts.ScrapeTimeoutOffset
+	e.mutex.RUnlock()
+	if header := r.Header.Get(scrapeTimeoutHeader); header != "" {
+		seconds, err := strconv.ParseFloat(header, 64)
+		if err != nil {
+			log.Errorf("parsing %s header: %s", scrapeTimeoutHeader, err)
+		} else if t := time.Duration(seconds*float64(time.Second)) - offset; t > 0 {
+			timeout = t
+		}
+	}
+	if timeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithTimeout(r.Context(), timeout)
+}
+
+// contextCollector collects the metrics of an exporter within the context.
+type contextCollector struct {
+	ctx      context.Context
+	exporter *Exporter
+}
+
+// Describe implements the prometheus.Collector.
+func (c contextCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.exporter.Describe(ch)
+}
+
+// Collect implements the promtheus.Collector.
+func (c contextCollector) Collect(ch chan<- prometheus.Metric) {
+	c.exporter.collect(c.ctx, ch)
+}
@@ -0,0 +1,49 @@
+package exporter
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestScrapeContext(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		timeout time.Duration
+		offset  time.Duration
+		// Expected timeout of the context, zero meaning no deadline.
+		want time.Duration
+	}{
+		{name: "no timeout"},
+		{name: "scrape timeout", timeout: 5 * time.Second, want: 5 * time.Second},
+		{name: "header", header: "10", offset: 500 * time.Millisecond, want: 9500 * time.Millisecond},
+		{name: "header overrides scrape timeout", header: "2.5", timeout: 5 * time.Second, want: 2500 * time.Millisecond},
+		{name: "header within offset", header: "0.5", timeout: 5 * time.Second, offset: 500 * time.Millisecond, want: 5 * time.Second},
+		{name: "header within offset without timeout", header: "0.25", offset: 500 * time.Millisecond},
+		{name: "invalid header", header: "10s", timeout: 5 * time.Second, offset: 500 * time.Millisecond, want: 5 * time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := newExporter(nil)
+			e.opts.ScrapeTimeout, e.opts.ScrapeTimeoutOffset = test.timeout, test.offset
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if test.header != "" {
+				r.Header.Set(scrapeTimeoutHeader, test.header)
+			}
+
+			start := time.Now()
+			ctx, cancel := e.scrapeContext(r)
+			defer cancel()
+			deadline, ok := ctx.Deadline()
+			if ok != (test.want > 0) {
+				t.Fatalf("context has deadline %t, want %t", ok, test.want > 0)
+			}
+			// The deadline is set between the start and now.
+			if timeout := deadline.Sub(start); ok && (timeout < test.want || timeout > test.want+time.Since(start)) {
+				t.Errorf("timeout = %s, want %s", timeout, test.want)
+			}
+		})
+	}
+}
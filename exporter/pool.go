@@ -0,0 +1,108 @@
+package exporter
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const poolSubsystem = "pool"
+
+// poolMetrics describe the connection pool statistics of the targets, to size pools and
+// spot scrapes queueing for connections.
+type poolMetrics struct {
+	acquiredConns        *prometheus.Desc
+	idleConns            *prometheus.Desc
+	constructingConns    *prometheus.Desc
+	totalConns           *prometheus.Desc
+	maxConns             *prometheus.Desc
+	acquireCount         *prometheus.Desc
+	acquireDuration      *prometheus.Desc
+	emptyAcquireCount    *prometheus.Desc
+	canceledAcquireCount *prometheus.Desc
+}
+
+func newPoolMetrics() poolMetrics {
+	variableLabels := []string{"target"}
+	return poolMetrics{
+		acquiredConns: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, poolSubsystem, "acquired_connections"),
+			"Number of connections of the pool currently acquired.",
+			variableLabels,
+			nil,
+		),
+		idleConns: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, poolSubsystem, "idle_connections"),
+			"Number of idle connections of the pool.",
+			variableLabels,
+			nil,
+		),
+		constructingConns: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, poolSubsystem, "constructing_connections"),
+			"Number of connections of the pool being constructed.",
+			variableLabels,
+			nil,
+		),
+		totalConns: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, poolSubsystem, "total_connections"),
+			"Total number of connections of the pool, acquired, idle and being constructed.",
+			variableLabels,
+			nil,
+		),
+		maxConns: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, poolSubsystem, "max_connections"),
+			"Maximum number of connections of the pool.",
+			variableLabels,
+			nil,
+		),
+		acquireCount: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, poolSubsystem, "acquires_total"),
+			"Total successful acquires of connections from the pool.",
+			variableLabels,
+			nil,
+		),
+		acquireDuration: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, poolSubsystem, "acquire_duration_seconds_total"),
+			"Total time spent on successful acquires of connections from the pool.",
+			variableLabels,
+			nil,
+		),
+		emptyAcquireCount: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, poolSubsystem, "empty_acquires_total"),
+			"Total successful acquires that waited for a connection as the pool was empty.",
+			variableLabels,
+			nil,
+		),
+		canceledAcquireCount: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, poolSubsystem, "canceled_acquires_total"),
+			"Total acquires canceled by their context.",
+			variableLabels,
+			nil,
+		),
+	}
+}
+
+func (m poolMetrics) describe(ch chan<- *prometheus.Desc) {
+	ch <- m.acquiredConns
+	ch <- m.idleConns
+	ch <- m.constructingConns
+	ch <- m.totalConns
+	ch <- m.maxConns
+	ch <- m.acquireCount
+	ch <- m.acquireDuration
+	ch <- m.emptyAcquireCount
+	ch <- m.canceledAcquireCount
+}
+
+// collect reports the statistics of the target's pool, which are read without querying the database.
+func (m poolMetrics) collect(target *target, ch chan<- prometheus.Metric) {
+	stat := target.dbClient.PoolStat()
+	name := target.name()
+	ch <- prometheus.MustNewConstMetric(m.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()), name)
+	ch <- prometheus.MustNewConstMetric(m.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()), name)
+	ch <- prometheus.MustNewConstMetric(m.constructingConns, prometheus.GaugeValue, float64(stat.ConstructingConns()), name)
+	ch <- prometheus.MustNewConstMetric(m.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()), name)
+	ch <- prometheus.MustNewConstMetric(m.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()), name)
+	ch <- prometheus.MustNewConstMetric(m.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()), name)
+	ch <- prometheus.MustNewConstMetric(m.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds(), name)
+	ch <- prometheus.MustNewConstMetric(m.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()), name)
+	ch <- prometheus.MustNewConstMetric(m.canceledAcquireCount, prometheus.CounterValue, float64(stat.CanceledAcquireCount()), name)
+}
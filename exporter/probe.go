@@ -0,0 +1,226 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/odonate/postgres-exporter/exporter/collectors"
+	"github.com/odonate/postgres-exporter/exporter/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// errInvalidTarget is returned for probes of targets that are neither configured nor allowed DSNs.
+var errInvalidTarget = errors.New("invalid target")
+
+// ProbeHandler serves the metrics of a single target, given by the target query parameter
+// as either the name of a configured database or a DSN of an allowed host (see Opts.ProbeHosts),
+// e.g. /probe?target=orders.
+// Clients of probed targets are cached and reused across probes, until they have been idle for
+// probeClientTTL or are the least recently used beyond maxProbeClients.
+func (e *Exporter) ProbeHandler() http.Handler {
+	return http.HandlerFunc(e.probe)
+}
+
+func (e *Exporter) probe(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("target")
+	if name == "" {
+		http.Error(w, "target parameter is missing", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel := e.scrapeContext(r)
+	defer cancel()
+	registry := prometheus.NewRegistry()
+	probed, release, err := e.probeTarget(ctx, name)
+	if errors.Is(err, errInvalidTarget) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		// Only the up metric is exported for targets we cannot connect to.
+		log.Errorf("probing target: %s", err)
+		up := prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: namespace, Name: "up", Help: upHelp}, []string{"target"})
+		up.WithLabelValues(probeTargetName(name)).Set(0)
+		registry.MustRegister(up)
+	} else {
+		defer release()
+		registry.MustRegister(contextCollector{ctx: ctx, exporter: newExporter([]*target{probed})})
+	}
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// probeTarget returns the target with the given name or DSN, connecting to it if required.
+// The target is not closed, by a reload or eviction, until release is called.
+func (e *Exporter) probeTarget(ctx context.Context, name string) (probed *target, release func(), err error) {
+	e.mutex.RLock()
+	for _, target := range e.targets {
+		if target.dbClient.Name() == name {
+			// A reload removing the target closes it once the probe is done with it.
+			target.probes.Add(1)
+			e.mutex.RUnlock()
+			return target, target.probes.Done, nil
+		}
+	}
+	e.mutex.RUnlock()
+	if !strings.Contains(name, "://") && !strings.Contains(name, "=") {
+		return nil, nil, fmt.Errorf("%w: unknown target %q", errInvalidTarget, name)
+	}
+	client, err := e.probeClient(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	probed = &target{
+		dbClient:   client.dbClient,
+		labels:     labelPairs(client.dbClient.Name(), nil),
+		collectors: e.probeCollectors(client.dbClient),
+	}
+	return probed, func() { e.releaseProbeClient(client) }, nil
+}
+
+// probeClient returns the cached client of the DSN, connecting to it if required, and marks it
+// as in use. Connecting does not hold up probes of other targets.
+func (e *Exporter) probeClient(ctx context.Context, dsn string) (*probeClient, error) {
+	e.probeMutex.Lock()
+	client, ok := e.probeClients[dsn]
+	if ok {
+		client.inUse++
+	}
+	e.probeMutex.Unlock()
+	if ok {
+		return client, nil
+	}
+
+	opts, err := e.probeOpts(dsn)
+	if err != nil {
+		return nil, err
+	}
+	// Unlike configured targets, probed ones are connected to on demand.
+	dbClient, err := db.New(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to %s: %w", opts.Host, err)
+	}
+	e.probeMutex.Lock()
+	client, ok = e.probeClients[dsn]
+	if !ok {
+		client = &probeClient{dbClient: dbClient}
+		e.probeClients[dsn] = client
+	}
+	client.inUse++
+	e.probeMutex.Unlock()
+	if ok {
+		// A concurrent probe of the DSN connected first.
+		dbClient.Close()
+	}
+	return client, nil
+}
+
+// Bounds of the clients of probed targets, so that probing many DSNs does not leak connection pools.
+const (
+	probeClientTTL  = 10 * time.Minute
+	maxProbeClients = 64
+)
+
+// probeClient is the cached client of a probed target.
+type probeClient struct {
+	dbClient *db.Client
+	lastUsed time.Time
+	// inUse counts the in-flight probes of the client, which is not evicted until they are done.
+	inUse int
+}
+
+// releaseProbeClient marks the client as done with by a probe, evicting it once idle for probeClientTTL.
+func (e *Exporter) releaseProbeClient(client *probeClient) {
+	e.probeMutex.Lock()
+	client.inUse--
+	client.lastUsed = time.Now()
+	e.probeMutex.Unlock()
+	e.evictProbeClients(time.Now().Add(-probeClientTTL))
+	time.AfterFunc(probeClientTTL, func() {
+		e.evictProbeClients(time.Now().Add(-probeClientTTL))
+	})
+}
+
+// evictProbeClients closes the idle clients of probed targets last used before idleSince, and the
+// least recently used idle ones beyond maxProbeClients.
+func (e *Exporter) evictProbeClients(idleSince time.Time) {
+	e.probeMutex.Lock()
+	defer e.probeMutex.Unlock()
+	idle := make([]string, 0, len(e.probeClients))
+	for name, client := range e.probeClients {
+		if client.inUse == 0 {
+			idle = append(idle, name)
+		}
+	}
+	sort.Slice(idle, func(i, j int) bool {
+		return e.probeClients[idle[i]].lastUsed.Before(e.probeClients[idle[j]].lastUsed)
+	})
+	for _, name := range idle {
+		client := e.probeClients[name]
+		if !client.lastUsed.Before(idleSince) && len(e.probeClients) <= maxProbeClients {
+			break
+		}
+		log.Infof("closing idle probe client of %s", client.dbClient.Name())
+		client.dbClient.Close()
+		delete(e.probeClients, name)
+	}
+}
+
+// probeCollectors instantiates the collectors run against DSN targets.
+func (e *Exporter) probeCollectors(dbClient *db.Client) []namedCollector {
+	e.mutex.RLock()
+	collectorOpts := e.opts.collectorOpts()
+	e.mutex.RUnlock()
+	dbClients := []*db.Client{dbClient}
+	names := collectors.DefaultNames()
+	probeCollectors := make([]namedCollector, 0, len(names)+len(e.factories))
+	for _, name := range names {
+		factory, _ := collectors.LookupFactory(name, collectorOpts)
+		probeCollectors = append(probeCollectors, namedCollector{Collector: factory(dbClients), name: name})
+	}
+	return append(probeCollectors, newNamedCollectors(dbClients, e.factories...)...)
+}
+
+// probeOpts resolves the db opts of the DSN.
+func (e *Exporter) probeOpts(dsn string) (db.Opts, error) {
+	e.mutex.RLock()
+	defaults := e.opts.targets()[0].DBOpts
+	if e.opts.ProbeDBOpts != nil {
+		defaults = *e.opts.ProbeDBOpts
+	}
+	probeHosts := e.opts.ProbeHosts
+	e.mutex.RUnlock()
+	opts, err := db.OptsFromDSN(dsn, defaults)
+	if err != nil {
+		return db.Opts{}, fmt.Errorf("%w: %s", errInvalidTarget, err)
+	}
+	if !contains(probeHosts, "*") && !contains(probeHosts, opts.Host) {
+		return db.Opts{}, fmt.Errorf("%w: host %q is not allowed to be probed", errInvalidTarget, opts.Host)
+	}
+	// Probes should fail fast rather than hold up the scrape.
+	opts.MaxConnectionRetries = 0
+	return opts, nil
+}
+
+// probeTargetName returns the name of the target reported in metrics, leaving out
+// credentials of DSNs.
+func probeTargetName(name string) string {
+	opts, err := db.OptsFromDSN(name, db.Opts{})
+	if err != nil {
+		return name
+	}
+	return opts.TargetName()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
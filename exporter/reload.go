@@ -0,0 +1,112 @@
+package exporter
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloadDebounce is how long to wait for a config file to settle before reloading it,
+// since editors and Kubernetes tend to write files over several events.
+const reloadDebounce = 500 * time.Millisecond
+
+// Reloader reloads an Exporter with the opts returned by load, on demand.
+type Reloader struct {
+	exporter *Exporter
+	load     func() (Opts, error)
+}
+
+// NewReloader instantiates and returns a new Reloader.
+func NewReloader(exporter *Exporter, load func() (Opts, error)) *Reloader {
+	return &Reloader{
+		exporter: exporter,
+		load:     load,
+	}
+}
+
+// Reload loads the opts and reloads the exporter with them.
+func (r *Reloader) Reload(ctx context.Context) error {
+	return r.exporter.loadAndReload(ctx, r.load)
+}
+
+// Handler reloads the exporter on POST requests, e.g. to /-/reload.
+func (r *Reloader) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost && req.Method != http.MethodPut {
+			w.Header().Set("Allow", "POST, PUT")
+			http.Error(w, "only POST or PUT requests allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err := r.Reload(req.Context()); err != nil {
+			log.Errorf("reloading: %s", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte("reloaded"))
+	})
+}
+
+// WatchSignals reloads the exporter on SIGHUP until the context is done.
+func (r *Reloader) WatchSignals(ctx context.Context) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			log.Infof("reloading on SIGHUP")
+			if err := r.Reload(ctx); err != nil {
+				log.Errorf("reloading: %s", err)
+			}
+		}
+	}
+}
+
+// WatchFile reloads the exporter whenever the file at path changes, until the context is done.
+// The parent directory is watched so that files replaced by renames, such as Kubernetes
+// ConfigMaps, are picked up too.
+func (r *Reloader) WatchFile(ctx context.Context, path string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	path = filepath.Clean(path)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return err
+	}
+
+	realPath, _ := filepath.EvalSymlinks(path)
+	debounce := time.NewTimer(0)
+	<-debounce.C
+	for {
+		select {
+		case <-ctx.Done():
+			debounce.Stop()
+			return nil
+		case err := <-watcher.Errors:
+			log.Errorf("watching %s: %s", path, err)
+		case event := <-watcher.Events:
+			// ConfigMaps are updated by swapping the symlink the file resolves through.
+			newRealPath, _ := filepath.EvalSymlinks(path)
+			if filepath.Clean(event.Name) != path && newRealPath == realPath {
+				continue
+			}
+			realPath = newRealPath
+			debounce.Reset(reloadDebounce)
+		case <-debounce.C:
+			log.Infof("reloading on change to %s", path)
+			if err := r.Reload(ctx); err != nil {
+				log.Errorf("reloading: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package exporter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/odonate/postgres-exporter/exporter/collectors"
+	"github.com/odonate/postgres-exporter/exporter/db"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// target is a database scraped by the exporter.
+type target struct {
+	opts       Target
+	dbClient   *db.Client
+	labels     []*dto.LabelPair
+	collectors []namedCollector
+	// background scrapes the collectors, nil unless background scrapes are enabled.
+	background *background
+	// probes counts the in-flight probes of the target, which is not closed until they are done.
+	probes sync.WaitGroup
+}
+
+// namedCollector is a collector along with its name, as reported in metrics.
+type namedCollector struct {
+	collectors.Collector
+	name string
+}
+
+// newNamedCollectors instantiates a collector from every factory, named by type.
+func newNamedCollectors(dbClients []*db.Client, factories ...collectors.Factory) []namedCollector {
+	named := make([]namedCollector, 0, len(factories))
+	for _, collector := range collectors.NewCollectors(dbClients, factories...) {
+		named = append(named, namedCollector{Collector: collector, name: collectorName(collector)})
+	}
+	return named
+}
+
+// collectorName names a custom collector by its type, e.g. collectors.QueriesCollector.
+func collectorName(collector collectors.Collector) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", collector), "*")
+}
+
+// newTarget instantiates the collectors of the target, followed by one collector per custom
+// factory. Its database is connected to in the background, until which the target is down.
+func newTarget(opts Target, factories []collectors.Factory) (*target, error) {
+	if err := checkLabels(opts.Labels); err != nil {
+		return nil, err
+	}
+	namedFactories, err := lookupFactories(opts)
+	if err != nil {
+		return nil, err
+	}
+	dbClient, err := db.NewLazy(opts.DBOpts)
+	if err != nil {
+		return nil, err
+	}
+	return newTargetOf(opts, dbClient, namedFactories, factories), nil
+}
+
+// rebuild instantiates the target anew from opts that differ in collectors or labels only,
+// sharing the connections of t.
+func (t *target) rebuild(opts Target, factories []collectors.Factory) (*target, error) {
+	if err := checkLabels(opts.Labels); err != nil {
+		return nil, err
+	}
+	namedFactories, err := lookupFactories(opts)
+	if err != nil {
+		return nil, err
+	}
+	return newTargetOf(opts, t.dbClient, namedFactories, factories), nil
+}
+
+// ReservedLabelNames returns the names of the labels of the metrics of the exporter and its
+// collectors, which the labels of targets must not collide with.
+func ReservedLabelNames() []string {
+	return append([]string{"class", "collector", "short_version", "target", "version"}, collectors.LabelNames()...)
+}
+
+// checkLabels checks that the labels of a target do not collide with reserved ones, which
+// would fail the gathering of every metric carrying both.
+func checkLabels(labels map[string]string) error {
+	for _, name := range ReservedLabelNames() {
+		if _, ok := labels[name]; ok {
+			return fmt.Errorf("label %q is reserved by the exporter", name)
+		}
+	}
+	return nil
+}
+
+// checkNames checks that every target has its own name, which its metrics are labelled with.
+func checkNames(targets []Target) error {
+	names := make(map[string]struct{}, len(targets))
+	for _, target := range targets {
+		name := target.DBOpts.TargetName()
+		if _, ok := names[name]; ok {
+			return fmt.Errorf("duplicate target %q", name)
+		}
+		names[name] = struct{}{}
+	}
+	return nil
+}
+
+// lookupFactories returns the factories of the collectors of the target, by name.
+func lookupFactories(opts Target) (map[string]collectors.Factory, error) {
+	collectorOpts := collectors.DefaultOpts()
+	if opts.CollectorOpts != nil {
+		collectorOpts = *opts.CollectorOpts
+	}
+	namedFactories := make(map[string]collectors.Factory, len(opts.names()))
+	for _, name := range opts.names() {
+		factory, ok := collectors.LookupFactory(name, collectorOpts)
+		if !ok {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+		namedFactories[name] = factory
+	}
+	return namedFactories, nil
+}
+
+// names returns the names of the collectors of the target.
+func (o Target) names() []string {
+	if len(o.Collectors) == 0 {
+		return collectors.DefaultNames()
+	}
+	return o.Collectors
+}
+
+func newTargetOf(opts Target, dbClient *db.Client, namedFactories map[string]collectors.Factory, factories []collectors.Factory) *target {
+	dbClients := []*db.Client{dbClient}
+	names := opts.names()
+	targetCollectors := make([]namedCollector, 0, len(names)+len(factories))
+	for _, name := range names {
+		targetCollectors = append(targetCollectors, namedCollector{Collector: namedFactories[name](dbClients), name: name})
+	}
+	return &target{
+		opts:       opts,
+		dbClient:   dbClient,
+		labels:     labelPairs(dbClient.Name(), opts.Labels),
+		collectors: append(targetCollectors, newNamedCollectors(dbClients, factories...)...),
+	}
+}
+
+// name returns the name of the target, as reported in metrics.
+func (t *target) name() string {
+	return t.dbClient.Name()
+}
+
+// close stops the background scrapes of the target and closes its connection pool, once
+// in-flight probes are done with it.
+func (t *target) close() {
+	t.probes.Wait()
+	t.background.stop()
+	t.dbClient.Close()
+}
+
+// label adds the labels of the target to the metric.
+func (t *target) label(metric prometheus.Metric) prometheus.Metric {
+	return labelledMetric{Metric: metric, labels: t.labels}
+}
+
+// labelPairs returns the target label, naming the target so that targets of the same database
+// do not export the same series, followed by the labels of the target.
+func labelPairs(targetName string, labels map[string]string) []*dto.LabelPair {
+	pairs := make([]*dto.LabelPair, 0, len(labels)+1)
+	pairs = append(pairs, &dto.LabelPair{
+		Name:  proto.String("target"),
+		Value: proto.String(targetName),
+	})
+	for name, value := range labels {
+		pairs = append(pairs, &dto.LabelPair{
+			Name:  proto.String(name),
+			Value: proto.String(value),
+		})
+	}
+	return pairs
+}
+
+// labelledMetric adds constant labels to a metric.
+type labelledMetric struct {
+	prometheus.Metric
+	labels []*dto.LabelPair
+}
+
+// Write implements the prometheus.Metric.
+func (m labelledMetric) Write(out *dto.Metric) error {
+	if err := m.Metric.Write(out); err != nil {
+		return err
+	}
+	// Labels of custom collectors win over colliding labels of the target.
+	for _, label := range m.labels {
+		if !hasLabel(out.Label, label.GetName()) {
+			out.Label = append(out.Label, label)
+		}
+	}
+	sort.Slice(out.Label, func(i, j int) bool { return out.Label[i].GetName() < out.Label[j].GetName() })
+	return nil
+}
+
+func hasLabel(labels []*dto.LabelPair, name string) bool {
+	for _, label := range labels {
+		if label.GetName() == name {
+			return true
+		}
+	}
+	return false
+}